@@ -2,19 +2,39 @@ package input
 
 import (
 	"log"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 
 	"github.com/aretext/aretext/state"
 )
 
+// maxMacroReplayDepth bounds how many macro replays can be nested (a macro
+// replaying a register that (transitively) replays itself). A replay past this
+// depth does nothing (replayRegister returns EmptyAction), so a self-
+// referential macro stops rather than recursing forever.
+const maxMacroReplayDepth = 100
+
 // Interpreter translates key events to commands.
 type Interpreter struct {
 	modes map[state.InputMode]Mode
+
+	recording          bool
+	recordingRegister  rune
+	recordBuffer       []tcell.EventKey
+	registers          map[rune][]tcell.EventKey
+	macroRegisters     *state.MacroRegisters
+	pendingOp          rune // 'q' or '@' while waiting for the register rune that follows it
+	replaying          bool
+	replayDepth        int
+	lastPlayedRegister rune
 }
 
-// NewInterpreter creates a new interpreter.
-func NewInterpreter() *Interpreter {
+// NewInterpreter creates a new interpreter. macroRegisters receives a
+// summary of every macro recorded during the session (e.g. for a `:reg`
+// command to list); the interpreter itself still owns the recorded events,
+// since nothing outside it ever needs to replay them.
+func NewInterpreter(macroRegisters *state.MacroRegisters) *Interpreter {
 	return &Interpreter{
 		modes: map[state.InputMode]Mode{
 			state.InputModeNormal: newVmMode("normal", normalModeCommands()),
@@ -24,15 +44,20 @@ func NewInterpreter() *Interpreter {
 			state.InputModeVisual: newVmMode("visual", visualModeCommands()),
 			state.InputModeTask:   &taskMode{},
 		},
+		registers:      make(map[rune][]tcell.EventKey),
+		macroRegisters: macroRegisters,
 	}
 }
 
 // ProcessEvent interprets a terminal input event as an action.
 // (If there is no action, then EmptyAction will be returned.)
-func (inp *Interpreter) ProcessEvent(event tcell.Event, config Config) Action {
+// s is the current editor state: ProcessEvent never reads it directly, but
+// passes it down to replayRegister, which needs it to apply a replayed
+// macro's intermediate actions (see replayRegister for why).
+func (inp *Interpreter) ProcessEvent(event tcell.Event, config Config, s *state.EditorState) Action {
 	switch event := event.(type) {
 	case *tcell.EventKey:
-		return inp.processKeyEvent(event, config)
+		return inp.processKeyEvent(event, config, s)
 	case *tcell.EventResize:
 		return inp.processResizeEvent(event)
 	default:
@@ -40,12 +65,145 @@ func (inp *Interpreter) ProcessEvent(event tcell.Event, config Config) Action {
 	}
 }
 
-func (inp *Interpreter) processKeyEvent(event *tcell.EventKey, config Config) Action {
+func (inp *Interpreter) processKeyEvent(event *tcell.EventKey, config Config, s *state.EditorState) Action {
 	log.Printf("Processing key %s in mode %s\n", event.Name(), config.InputMode)
+
+	if config.InputMode == state.InputModeNormal {
+		if action, handled := inp.processMacroKeyEvent(event, config, s); handled {
+			return action
+		}
+	}
+
 	mode := inp.modes[config.InputMode]
+	if inp.recording {
+		mode = newRecordingMode(mode, &inp.recordBuffer)
+	}
 	return mode.ProcessKeyEvent(event, config)
 }
 
+// processMacroKeyEvent intercepts the normal-mode keys that start/stop macro
+// recording (q<reg>, q) and trigger macro replay (@<reg>, @@), consuming them
+// so they're never forwarded to the underlying mode.
+func (inp *Interpreter) processMacroKeyEvent(event *tcell.EventKey, config Config, s *state.EditorState) (action Action, handled bool) {
+	if inp.pendingOp != 0 {
+		op := inp.pendingOp
+		inp.pendingOp = 0
+
+		if op == 'q' {
+			inp.startRecording(event.Rune())
+			return EmptyAction, true
+		}
+
+		// op == '@'
+		reg := event.Rune()
+		if reg == '@' {
+			reg = inp.lastPlayedRegister
+		}
+		return inp.replayRegister(reg, config, s), true
+	}
+
+	if event.Key() != tcell.KeyRune {
+		return EmptyAction, false
+	}
+
+	switch event.Rune() {
+	case 'q':
+		if inp.recording {
+			inp.stopRecording()
+		} else {
+			inp.pendingOp = 'q'
+		}
+		return EmptyAction, true
+	case '@':
+		inp.pendingOp = '@'
+		return EmptyAction, true
+	default:
+		return EmptyAction, false
+	}
+}
+
+func (inp *Interpreter) startRecording(register rune) {
+	inp.recording = true
+	inp.recordingRegister = register
+	inp.recordBuffer = inp.recordBuffer[:0]
+}
+
+func (inp *Interpreter) stopRecording() {
+	inp.recording = false
+	events := append([]tcell.EventKey(nil), inp.recordBuffer...)
+	inp.registers[inp.recordingRegister] = events
+	if inp.macroRegisters != nil {
+		inp.macroRegisters.SetMacro(inp.recordingRegister, macroSummary(events))
+	}
+	inp.recordBuffer = nil
+}
+
+// macroSummary renders a recorded macro's events as a string of the keys a
+// user would have typed, for display by a `:reg` command.
+func macroSummary(events []tcell.EventKey) string {
+	var b strings.Builder
+	for i := range events {
+		if events[i].Key() == tcell.KeyRune {
+			b.WriteRune(events[i].Rune())
+		} else {
+			b.WriteString(events[i].Name())
+		}
+	}
+	return b.String()
+}
+
+// replayRegister feeds a previously recorded register's events back through
+// processKeyEvent, as if the user had typed them, using whichever mode is
+// current (not necessarily the one active when the macro was recorded).
+// Replays can nest (a replayed macro can itself replay a register), so
+// replayDepth caps the nesting instead of letting a self-referential macro
+// recurse forever.
+//
+// Every event but the last has its action applied to s immediately, and
+// config.InputMode is refreshed from s before the next event is processed.
+// Nothing else will apply those intermediate actions, and without this a
+// macro that changes mode partway through (e.g. "A foo<Esc>", which enters
+// insert mode and leaves it again) would replay every remaining key under the
+// mode that was active when @ was first pressed instead of the mode the
+// macro itself switches into. The final event's action is left for the
+// caller to apply, consistent with every other call to ProcessEvent.
+func (inp *Interpreter) replayRegister(register rune, config Config, s *state.EditorState) Action {
+	events := inp.registers[register]
+	if len(events) == 0 || inp.replayDepth >= maxMacroReplayDepth {
+		return EmptyAction
+	}
+
+	inp.lastPlayedRegister = register
+	inp.replayDepth++
+	defer func() { inp.replayDepth-- }()
+
+	wasReplaying := inp.replaying
+	inp.replaying = true
+	defer func() { inp.replaying = wasReplaying }()
+
+	for i := range events {
+		action := inp.processKeyEvent(&events[i], config, s)
+		if i == len(events)-1 {
+			return action
+		}
+		action(s)
+		config.InputMode = s.InputMode()
+	}
+	return EmptyAction
+}
+
+// IsRecording reports whether the interpreter is currently recording a macro,
+// for display on the status line.
+func (inp *Interpreter) IsRecording() bool {
+	return inp.recording
+}
+
+// RecordingRegister returns the register currently being recorded into.
+// The second return value is false if no recording is in progress.
+func (inp *Interpreter) RecordingRegister() (rune, bool) {
+	return inp.recordingRegister, inp.recording
+}
+
 func (inp *Interpreter) processResizeEvent(event *tcell.EventResize) Action {
 	log.Printf("Processing resize event\n")
 	width, height := event.Size()
@@ -0,0 +1,173 @@
+package input
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aretext/aretext/state"
+)
+
+// fakeMode is a minimal Mode used to isolate Interpreter's macro-replay logic
+// from the real vm-based modes, which need a full command table to build.
+type fakeMode struct {
+	process func(event *tcell.EventKey, config Config) Action
+}
+
+func (m *fakeMode) ProcessKeyEvent(event *tcell.EventKey, config Config) Action {
+	return m.process(event, config)
+}
+
+func (m *fakeMode) InputBufferString() string { return "" }
+
+func newTestInterpreter(normal, insert Mode) *Interpreter {
+	return &Interpreter{
+		modes: map[state.InputMode]Mode{
+			state.InputModeNormal: normal,
+			state.InputModeInsert: insert,
+		},
+		registers:      make(map[rune][]tcell.EventKey),
+		macroRegisters: state.NewMacroRegisters(),
+	}
+}
+
+func runeEvent(r rune) *tcell.EventKey {
+	return tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+}
+
+func TestReplayRegisterAppliesEveryIntermediateAction(t *testing.T) {
+	var applied []rune
+	normal := &fakeMode{process: func(event *tcell.EventKey, config Config) Action {
+		r := event.Rune()
+		return func(s *state.EditorState) { applied = append(applied, r) }
+	}}
+
+	inp := newTestInterpreter(normal, normal)
+	s := state.NewEditorState(100, 100, nil)
+	config := Config{InputMode: state.InputModeNormal}
+
+	// Record register 'a' as the three keys x, y, z.
+	inp.ProcessEvent(runeEvent('q'), config, s)
+	inp.ProcessEvent(runeEvent('a'), config, s)
+	for _, r := range []rune{'x', 'y', 'z'} {
+		action := inp.ProcessEvent(runeEvent(r), config, s)
+		action(s)
+	}
+	inp.ProcessEvent(runeEvent('q'), config, s)
+	applied = nil
+
+	// Replay it with @a. Before the fix, only the action for 'z' (the last
+	// recorded event) ever reached any caller.
+	inp.ProcessEvent(runeEvent('@'), config, s)
+	last := inp.ProcessEvent(runeEvent('a'), config, s)
+	last(s)
+
+	assert.Equal(t, []rune{'x', 'y', 'z'}, applied)
+}
+
+func TestReplayRegisterRefreshesConfigAcrossModeChange(t *testing.T) {
+	var observedModes []state.InputMode
+
+	normal := &fakeMode{process: func(event *tcell.EventKey, config Config) Action {
+		observedModes = append(observedModes, config.InputMode)
+		if event.Rune() == 'i' {
+			return func(s *state.EditorState) { state.SetInputMode(s, state.InputModeInsert) }
+		}
+		return EmptyAction
+	}}
+	insert := &fakeMode{process: func(event *tcell.EventKey, config Config) Action {
+		observedModes = append(observedModes, config.InputMode)
+		if event.Key() == tcell.KeyEscape {
+			return func(s *state.EditorState) { state.SetInputMode(s, state.InputModeNormal) }
+		}
+		return EmptyAction
+	}}
+
+	inp := newTestInterpreter(normal, insert)
+	s := state.NewEditorState(100, 100, nil)
+	config := Config{InputMode: state.InputModeNormal}
+
+	// Record register 'a' as: i <Esc> x (enter insert mode, leave it, then a
+	// normal-mode key). The external caller is responsible for refreshing
+	// config.InputMode from s after applying each action, same as the real
+	// editor loop would.
+	inp.ProcessEvent(runeEvent('q'), config, s)
+	inp.ProcessEvent(runeEvent('a'), config, s)
+
+	action := inp.ProcessEvent(runeEvent('i'), config, s)
+	action(s)
+	config.InputMode = s.InputMode()
+
+	escEvent := tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone)
+	action = inp.ProcessEvent(escEvent, config, s)
+	action(s)
+	config.InputMode = s.InputMode()
+
+	action = inp.ProcessEvent(runeEvent('x'), config, s)
+	action(s)
+	config.InputMode = s.InputMode()
+
+	inp.ProcessEvent(runeEvent('q'), config, s)
+
+	// Replay 'a' starting from normal mode, as if @a had just been pressed.
+	observedModes = nil
+	config.InputMode = state.InputModeNormal
+	inp.ProcessEvent(runeEvent('@'), config, s)
+	last := inp.ProcessEvent(runeEvent('a'), config, s)
+	last(s)
+
+	// Before the fix, config.InputMode never changed during the replay loop,
+	// so every replayed key would have been seen in normal mode.
+	assert.Equal(t, []state.InputMode{
+		state.InputModeNormal, // i
+		state.InputModeInsert, // <Esc>
+		state.InputModeNormal, // x
+	}, observedModes)
+}
+
+func TestStopRecordingPersistsMacroSummaryToState(t *testing.T) {
+	normal := &fakeMode{process: func(event *tcell.EventKey, config Config) Action {
+		return EmptyAction
+	}}
+
+	inp := newTestInterpreter(normal, normal)
+	s := state.NewEditorState(100, 100, nil)
+	config := Config{InputMode: state.InputModeNormal}
+
+	inp.ProcessEvent(runeEvent('q'), config, s)
+	inp.ProcessEvent(runeEvent('a'), config, s)
+	for _, r := range []rune{'x', 'y', 'z'} {
+		inp.ProcessEvent(runeEvent(r), config, s)
+	}
+	inp.ProcessEvent(runeEvent('q'), config, s)
+
+	// The macro must be discoverable through state.MacroRegisters, not just
+	// on the interpreter itself, so a :reg command can list it.
+	assert.Equal(t, []rune{'a'}, inp.macroRegisters.RegisterNames())
+	summary, ok := inp.macroRegisters.Macro('a')
+	assert.True(t, ok)
+	assert.Equal(t, "xyz", summary)
+}
+
+func TestNestedMacroReplay(t *testing.T) {
+	var applied []rune
+	normal := &fakeMode{process: func(event *tcell.EventKey, config Config) Action {
+		r := event.Rune()
+		return func(s *state.EditorState) { applied = append(applied, r) }
+	}}
+
+	inp := newTestInterpreter(normal, normal)
+	s := state.NewEditorState(100, 100, nil)
+	config := Config{InputMode: state.InputModeNormal}
+
+	// Register 'a' replays register 'b' (@b) partway through, then runs a
+	// trailing key of its own.
+	inp.registers['b'] = []tcell.EventKey{*runeEvent('y'), *runeEvent('z')}
+	inp.registers['a'] = []tcell.EventKey{*runeEvent('@'), *runeEvent('b'), *runeEvent('w')}
+
+	last := inp.replayRegister('a', config, s)
+	last(s)
+
+	assert.Equal(t, []rune{'y', 'z', 'w'}, applied)
+}
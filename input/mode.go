@@ -89,6 +89,28 @@ func (m *vmMode) InputBufferString() string {
 	return m.inputBuffer.String()
 }
 
+// recordingMode wraps another mode, appending every key event it processes to a
+// register's event buffer before delegating to the wrapped mode. This lets macro
+// recording tee input transparently regardless of which mode is active underneath,
+// so a recorded macro can span mode transitions (e.g. entering and leaving insert mode).
+type recordingMode struct {
+	wrapped Mode
+	buffer  *[]tcell.EventKey
+}
+
+func newRecordingMode(wrapped Mode, buffer *[]tcell.EventKey) *recordingMode {
+	return &recordingMode{wrapped, buffer}
+}
+
+func (m *recordingMode) ProcessKeyEvent(event *tcell.EventKey, config Config) Action {
+	*m.buffer = append(*m.buffer, *event)
+	return m.wrapped.ProcessKeyEvent(event, config)
+}
+
+func (m *recordingMode) InputBufferString() string {
+	return m.wrapped.InputBufferString()
+}
+
 // taskMode is used while a task is running asynchronously.
 // This allows the user to cancel the task if it takes too long.
 type taskMode struct{}
@@ -0,0 +1,365 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wedaly/aretext/internal/pkg/text"
+)
+
+// ErrBadAddressSyntax is returned when an address expression can't be parsed.
+var ErrBadAddressSyntax = errors.New("bad address syntax")
+
+// ErrAddressOutOfRange is returned when an address resolves to a position
+// outside the document.
+var ErrAddressOutOfRange = errors.New("address out of range")
+
+// ErrNoMatchForRegex is returned when a regex sub-address has no match.
+var ErrNoMatchForRegex = errors.New("no match for regex")
+
+// rangeState is a resolved span between two cursor positions, the result of
+// evaluating a range address (formed with ',' or ';').
+type rangeState struct {
+	start cursorState
+	end   cursorState
+}
+
+// AddressResult is what an AddressLocator resolves to: either a single cursor
+// position (a point address like "123" or "/foo/") or a span between two
+// positions (a range address like ".,$" or "/foo/,/bar/").
+type AddressResult struct {
+	IsRange bool
+	Point   cursorState
+	Range   rangeState
+}
+
+// addrSimple is a single, non-relative address: a line number, a char offset,
+// dot (the cursor), end of file, or a forward/backward regex search.
+type addrSimple struct {
+	isLine    bool
+	isChar    bool
+	isDot     bool
+	isEnd     bool
+	isForward bool // regex search direction, only meaningful when pattern != ""
+	isBackward bool
+	n         uint64
+	pattern   string
+}
+
+// addrCompound is a simple address optionally adjusted by a chain of +/- terms,
+// each of which is itself a (possibly implicit) simple address.
+type addrCompound struct {
+	base  *addrSimple // nil means "start from dot"
+	terms []addrTerm
+}
+
+type addrTerm struct {
+	sign int // +1 or -1
+	// addr is nil for a bare "+"/"-", which means "one line".
+	addr *addrSimple
+}
+
+// AddressLocator parses and resolves Acme/sam-style compound address
+// expressions (e.g. "123", "+#40", "-/foo/", ".,$", "/pat/+2") against the
+// current cursor and document.
+type AddressLocator struct {
+	expr addrExpr
+}
+
+// addrExpr is either a single compound address (a point) or two compound
+// addresses joined by ',' or ';' (a range).
+type addrExpr struct {
+	first  addrCompound
+	isRange bool
+	second addrCompound
+	// semicolon is true when the range was formed with ';', which (unlike ',')
+	// resolves the second address relative to the position the first resolved to.
+	semicolon bool
+}
+
+// NewAddressLocator parses addr and returns an AddressLocator that resolves it.
+// AddressLocator has no Locate method and does not implement Locator: it is
+// evaluated with Resolve, which returns a point or a range, or an error.
+func NewAddressLocator(addr string) (*AddressLocator, error) {
+	expr, pos, err := parseAddrExpr(addr, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(addr) {
+		return nil, ErrBadAddressSyntax
+	}
+	return &AddressLocator{expr: expr}, nil
+}
+
+func (loc *AddressLocator) String() string {
+	return "AddressLocator()"
+}
+
+// Resolve evaluates the address against state, returning either a point or a range.
+func (loc *AddressLocator) Resolve(state *State) (AddressResult, error) {
+	firstPos, err := resolveCompound(state, loc.expr.first, state.cursor.position)
+	if err != nil {
+		return AddressResult{}, err
+	}
+
+	if !loc.expr.isRange {
+		return AddressResult{Point: cursorState{position: firstPos}}, nil
+	}
+
+	secondBase := state.cursor.position
+	if loc.expr.semicolon {
+		secondBase = firstPos
+	}
+	secondPos, err := resolveCompound(state, loc.expr.second, secondBase)
+	if err != nil {
+		return AddressResult{}, err
+	}
+
+	return AddressResult{
+		IsRange: true,
+		Range: rangeState{
+			start: cursorState{position: firstPos},
+			end:   cursorState{position: secondPos},
+		},
+	}, nil
+}
+
+func resolveCompound(state *State, c addrCompound, dotPos uint64) (uint64, error) {
+	pos := dotPos
+
+	if c.base != nil {
+		p, err := resolveSimple(state, *c.base, dotPos)
+		if err != nil {
+			return 0, err
+		}
+		pos = p
+	}
+
+	for _, term := range c.terms {
+		if term.addr == nil {
+			// A bare "+" or "-" with no operand means one line.
+			pos = shiftByLines(state, pos, term.sign)
+			continue
+		}
+
+		if term.addr.isChar {
+			p, err := addSigned(pos, term.sign, term.addr.n)
+			if err != nil {
+				return 0, err
+			}
+			pos = p
+		} else if term.addr.isLine {
+			for i := uint64(0); i < term.addr.n; i++ {
+				pos = shiftByLines(state, pos, term.sign)
+			}
+		} else {
+			p, err := resolveSimple(state, *term.addr, pos)
+			if err != nil {
+				return 0, err
+			}
+			pos = p
+		}
+	}
+
+	if pos > state.tree.NumChars() {
+		return 0, ErrAddressOutOfRange
+	}
+
+	return pos, nil
+}
+
+// addSigned offsets pos by delta in the direction sign indicates, returning
+// ErrAddressOutOfRange instead of clamping if the result would underflow past
+// the start of the document.
+func addSigned(pos uint64, sign int, delta uint64) (uint64, error) {
+	if sign < 0 {
+		if delta > pos {
+			return 0, ErrAddressOutOfRange
+		}
+		return pos - delta, nil
+	}
+	return pos + delta, nil
+}
+
+// shiftByLines moves pos by one line up (sign < 0) or down (sign > 0), reusing
+// the line-motion logic already implemented for relativeLineLocator.
+func shiftByLines(state *State, pos uint64, sign int) uint64 {
+	direction := text.ReadDirectionForward
+	if sign < 0 {
+		direction = text.ReadDirectionBackward
+	}
+
+	scratch := &State{tree: state.tree, cursor: cursorState{position: pos}}
+	loc := NewRelativeLineLocator(direction, 1)
+	return loc.Locate(scratch).position
+}
+
+func resolveSimple(state *State, addr addrSimple, dotPos uint64) (uint64, error) {
+	switch {
+	case addr.isDot:
+		return dotPos, nil
+	case addr.isEnd:
+		return state.tree.NumChars(), nil
+	case addr.isChar:
+		return addr.n, nil
+	case addr.isLine:
+		return lineStartPos(state, addr.n)
+	case addr.pattern != "":
+		return resolveRegexAddr(state, addr, dotPos)
+	default:
+		return 0, ErrBadAddressSyntax
+	}
+}
+
+// lineStartPos finds the start of the nth line (1-indexed), matching Acme/sam's
+// line-numbering convention.
+func lineStartPos(state *State, n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, ErrAddressOutOfRange
+	}
+
+	pos := uint64(0)
+	loc := NewRelativeLineLocator(text.ReadDirectionForward, 1)
+	for line := uint64(1); line < n; line++ {
+		scratch := &State{tree: state.tree, cursor: cursorState{position: pos}}
+		newPos := loc.Locate(scratch).position
+		if newPos == pos {
+			return 0, ErrAddressOutOfRange
+		}
+		pos = newPos
+	}
+	return pos, nil
+}
+
+func resolveRegexAddr(state *State, addr addrSimple, dotPos uint64) (uint64, error) {
+	direction := text.ReadDirectionForward
+	if addr.isBackward {
+		direction = text.ReadDirectionBackward
+	}
+
+	loc, err := NewRegexSearchLocator(addr.pattern, direction, 1)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrBadAddressSyntax, err)
+	}
+
+	scratch := &State{tree: state.tree, cursor: cursorState{position: dotPos}}
+	result := loc.Locate(scratch)
+	if result.position == dotPos {
+		return 0, ErrNoMatchForRegex
+	}
+	return result.position, nil
+}
+
+// --- parsing ---
+
+func parseAddrExpr(s string, pos int) (addrExpr, int, error) {
+	first, pos, err := parseAddrCompound(s, pos)
+	if err != nil {
+		return addrExpr{}, 0, err
+	}
+
+	if pos < len(s) && (s[pos] == ',' || s[pos] == ';') {
+		semicolon := s[pos] == ';'
+		pos++
+		second, newPos, err := parseAddrCompound(s, pos)
+		if err != nil {
+			return addrExpr{}, 0, err
+		}
+		return addrExpr{first: first, isRange: true, second: second, semicolon: semicolon}, newPos, nil
+	}
+
+	return addrExpr{first: first}, pos, nil
+}
+
+func parseAddrCompound(s string, pos int) (addrCompound, int, error) {
+	var c addrCompound
+
+	if pos < len(s) && isSimpleAddrStart(s[pos]) {
+		addr, newPos, err := parseSimpleAddr(s, pos)
+		if err != nil {
+			return addrCompound{}, 0, err
+		}
+		c.base = &addr
+		pos = newPos
+	}
+
+	for pos < len(s) && (s[pos] == '+' || s[pos] == '-') {
+		sign := 1
+		if s[pos] == '-' {
+			sign = -1
+		}
+		pos++
+
+		if pos < len(s) && isSimpleAddrStart(s[pos]) {
+			addr, newPos, err := parseSimpleAddr(s, pos)
+			if err != nil {
+				return addrCompound{}, 0, err
+			}
+			c.terms = append(c.terms, addrTerm{sign: sign, addr: &addr})
+			pos = newPos
+		} else {
+			c.terms = append(c.terms, addrTerm{sign: sign})
+		}
+	}
+
+	if c.base == nil && len(c.terms) == 0 {
+		return addrCompound{}, 0, ErrBadAddressSyntax
+	}
+
+	return c, pos, nil
+}
+
+func isSimpleAddrStart(b byte) bool {
+	return b == '#' || b == '.' || b == '$' || b == '/' || b == '?' || (b >= '0' && b <= '9')
+}
+
+func parseSimpleAddr(s string, pos int) (addrSimple, int, error) {
+	switch {
+	case s[pos] == '.':
+		return addrSimple{isDot: true}, pos + 1, nil
+
+	case s[pos] == '$':
+		return addrSimple{isEnd: true}, pos + 1, nil
+
+	case s[pos] == '#':
+		n, newPos, ok := parseAddrUint(s, pos+1)
+		if !ok {
+			return addrSimple{}, 0, ErrBadAddressSyntax
+		}
+		return addrSimple{isChar: true, n: n}, newPos, nil
+
+	case s[pos] >= '0' && s[pos] <= '9':
+		n, newPos, ok := parseAddrUint(s, pos)
+		if !ok {
+			return addrSimple{}, 0, ErrBadAddressSyntax
+		}
+		return addrSimple{isLine: true, n: n}, newPos, nil
+
+	case s[pos] == '/' || s[pos] == '?':
+		delim := s[pos]
+		end := pos + 1
+		for end < len(s) && s[end] != delim {
+			end++
+		}
+		if end >= len(s) {
+			return addrSimple{}, 0, ErrBadAddressSyntax
+		}
+		return addrSimple{pattern: s[pos+1 : end], isForward: delim == '/', isBackward: delim == '?'}, end + 1, nil
+
+	default:
+		return addrSimple{}, 0, ErrBadAddressSyntax
+	}
+}
+
+func parseAddrUint(s string, pos int) (uint64, int, bool) {
+	start := pos
+	var n uint64
+	for pos < len(s) && s[pos] >= '0' && s[pos] <= '9' {
+		n = n*10 + uint64(s[pos]-'0')
+		pos++
+	}
+	if pos == start {
+		return 0, 0, false
+	}
+	return n, pos, true
+}
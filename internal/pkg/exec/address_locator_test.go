@@ -0,0 +1,74 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wedaly/aretext/internal/pkg/text"
+)
+
+// TestAddressLocatorUnderflowIsOutOfRange covers a backward char-offset
+// address that would underflow past the start of the document (e.g.
+// "#0-#5", five characters before offset 0). It must report
+// ErrAddressOutOfRange rather than silently clamping to 0.
+func TestAddressLocatorUnderflowIsOutOfRange(t *testing.T) {
+	tree, err := text.NewTreeFromString("abc")
+	assert.NoError(t, err)
+
+	loc, err := NewAddressLocator("#0-#5")
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	_, err = loc.Resolve(state)
+	assert.ErrorIs(t, err, ErrAddressOutOfRange)
+}
+
+// TestAddressLocatorRange covers a ',' range address ("#1,$"): the point
+// before the comma and "$" (end of file) resolve independently, both
+// relative to the cursor, and combine into a Range result.
+func TestAddressLocatorRange(t *testing.T) {
+	tree, err := text.NewTreeFromString("abcde")
+	assert.NoError(t, err)
+
+	loc, err := NewAddressLocator("#1,$")
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	result, err := loc.Resolve(state)
+	assert.NoError(t, err)
+	assert.True(t, result.IsRange)
+	assert.Equal(t, uint64(1), result.Range.start.position)
+	assert.Equal(t, uint64(5), result.Range.end.position)
+}
+
+// TestAddressLocatorSemicolonResolvesSecondRelativeToFirst covers a ';'
+// range address, which (unlike ',') resolves the second address relative to
+// the position the first one resolved to, rather than relative to dot.
+func TestAddressLocatorSemicolonResolvesSecondRelativeToFirst(t *testing.T) {
+	tree, err := text.NewTreeFromString("abcde")
+	assert.NoError(t, err)
+
+	loc, err := NewAddressLocator("#3;.")
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	result, err := loc.Resolve(state)
+	assert.NoError(t, err)
+	assert.True(t, result.IsRange)
+	assert.Equal(t, uint64(3), result.Range.start.position)
+	assert.Equal(t, uint64(3), result.Range.end.position)
+}
+
+// TestAddressLocatorOutOfRangeUpperBound covers a char offset past the end
+// of the document, the pre-existing upper-bound check in resolveCompound.
+func TestAddressLocatorOutOfRangeUpperBound(t *testing.T) {
+	tree, err := text.NewTreeFromString("abc")
+	assert.NoError(t, err)
+
+	loc, err := NewAddressLocator("#10")
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	_, err = loc.Resolve(state)
+	assert.ErrorIs(t, err, ErrAddressOutOfRange)
+}
@@ -0,0 +1,194 @@
+package exec
+
+import (
+	"fmt"
+
+	"github.com/wedaly/aretext/internal/pkg/syntax/parser"
+	"github.com/wedaly/aretext/internal/pkg/text"
+)
+
+// regexSearchLocator locates the start of the Nth match of a regular expression,
+// searching forward or backward from the cursor.
+type regexSearchLocator struct {
+	nfa       *parser.Nfa
+	prefix    *parser.Prefix
+	direction text.ReadDirection
+	count     uint64
+}
+
+// NewRegexSearchLocator builds a locator that moves the cursor to the start of
+// the Nth match of pattern after (or, for ReadDirectionBackward, before) the
+// current cursor position. If fewer than count matches are found, the cursor
+// is left at its current position.
+func NewRegexSearchLocator(pattern string, direction text.ReadDirection, count uint64) (Locator, error) {
+	if count == 0 {
+		panic("Count must be greater than zero")
+	}
+
+	regexp, err := parser.ParseRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("parser.ParseRegexp: %w", err)
+	}
+
+	return &regexSearchLocator{
+		nfa:       regexp.CompileNfa(),
+		prefix:    parser.ComputePrefix(regexp),
+		direction: direction,
+		count:     count,
+	}, nil
+}
+
+func (loc *regexSearchLocator) String() string {
+	return fmt.Sprintf("RegexSearchLocator(%s, %d)", directionString(loc.direction), loc.count)
+}
+
+// Locate finds the start of the Nth match in the search direction.
+// logicalOffset is reset to zero whenever the cursor moves, since a regex
+// match is always a concrete position rather than a remembered column.
+func (loc *regexSearchLocator) Locate(state *State) cursorState {
+	pos := state.cursor.position
+	var found uint64
+	for i := uint64(0); i < loc.count; i++ {
+		matchPos, ok := loc.findNextMatch(state.tree, pos)
+		if !ok {
+			return state.cursor
+		}
+		pos = matchPos
+		found++
+	}
+
+	if found < loc.count {
+		return state.cursor
+	}
+
+	return cursorState{position: pos}
+}
+
+// findNextMatch returns the position of the next match strictly after pos
+// (or, searching backward, strictly before pos).
+func (loc *regexSearchLocator) findNextMatch(tree *text.Tree, pos uint64) (uint64, bool) {
+	if loc.direction == text.ReadDirectionBackward {
+		return loc.findPrevMatch(tree, pos)
+	}
+
+	if loc.prefix != nil && loc.prefix.Anchored {
+		return loc.findNextAnchoredMatch(tree, pos)
+	}
+
+	numChars := tree.NumChars()
+	for candidate := pos + 1; candidate <= numChars; candidate++ {
+		if loc.matchAt(tree, candidate) {
+			return candidate, true
+		}
+	}
+
+	return 0, false
+}
+
+// findNextAnchoredMatch is findNextMatch's fast path for an anchored (^)
+// pattern. A plain per-position scan would call isLineStart (a backward tree
+// read) at every candidate just to reject almost all of them, which is still
+// O(n) tree reads for an O(number of lines) search; instead, this scans
+// forward once and only ever evaluates a candidate immediately after a
+// newline, jumping straight from line start to line start.
+func (loc *regexSearchLocator) findNextAnchoredMatch(tree *text.Tree, pos uint64) (uint64, bool) {
+	numChars := tree.NumChars()
+	runeIter := text.NewCloneableForwardRuneIter(tree.ReaderAtPosition(pos, text.ReadDirectionForward))
+
+	for idx := pos; idx < numChars; idx++ {
+		r, err := runeIter.NextRune()
+		if err != nil {
+			break
+		}
+		if r != '\n' {
+			continue
+		}
+
+		candidate := idx + 1
+		matchIter := text.NewCloneableForwardRuneIter(tree.ReaderAtPosition(candidate, text.ReadDirectionForward))
+		if !loc.prefixMayMatchAt(matchIter.Clone()) {
+			continue
+		}
+		if loc.nfa.MatchAtPosition(matchIter) {
+			return candidate, true
+		}
+	}
+
+	return 0, false
+}
+
+// matchAt reports whether the pattern matches starting at candidate, first
+// applying the same anchored-line-start and prefix fast-reject filters as a
+// cheap pre-check before the authoritative (and more expensive) NFA check.
+func (loc *regexSearchLocator) matchAt(tree *text.Tree, candidate uint64) bool {
+	if loc.prefix != nil && loc.prefix.Anchored && !loc.isLineStart(tree, candidate) {
+		return false
+	}
+
+	runeIter := text.NewCloneableForwardRuneIter(tree.ReaderAtPosition(candidate, text.ReadDirectionForward))
+	if loc.prefix != nil && !loc.prefixMayMatchAt(runeIter.Clone()) {
+		return false
+	}
+
+	return loc.nfa.MatchAtPosition(runeIter)
+}
+
+// isLineStart reports whether pos is the start of the text or immediately
+// follows a newline, which is where an anchored (^) pattern can match.
+func (loc *regexSearchLocator) isLineStart(tree *text.Tree, pos uint64) bool {
+	if pos == 0 {
+		return true
+	}
+	runeIter := text.NewCloneableBackwardRuneIter(tree.ReaderAtPosition(pos, text.ReadDirectionBackward))
+	r, err := runeIter.NextRune()
+	return err == nil && r == '\n'
+}
+
+// prefixMayMatchAt does a quick check of loc.prefix against the runes at the
+// iterator's current position, without invoking the full NFA step function.
+// It only returns false when a match is impossible, so it's safe to use as a
+// fast-reject filter before the authoritative (and more expensive) NFA check.
+func (loc *regexSearchLocator) prefixMayMatchAt(runeIter text.CloneableRuneIter) bool {
+	if len(loc.prefix.Literal) > 0 {
+		for _, want := range loc.prefix.Literal {
+			got, err := runeIter.NextRune()
+			if err != nil || got != want {
+				return false
+			}
+		}
+		return true
+	}
+
+	if loc.prefix.Set != nil {
+		r, err := runeIter.NextRune()
+		if err != nil {
+			return false
+		}
+		return loc.prefix.Set.Contains(r)
+	}
+
+	return true
+}
+
+// findPrevMatch scans backward from pos, trying each earlier position as a
+// candidate match start (matches are still verified by reading forward from
+// that position, since that's the direction the NFA was compiled to run in).
+// It applies the same prefix and anchored-line-start fast-reject filters as
+// findNextMatch, so backward search over a large buffer isn't left to fall
+// back to a full NFA scan at every position.
+func (loc *regexSearchLocator) findPrevMatch(tree *text.Tree, pos uint64) (uint64, bool) {
+	if pos == 0 {
+		return 0, false
+	}
+
+	for candidate := pos - 1; ; candidate-- {
+		if loc.matchAt(tree, candidate) {
+			return candidate, true
+		}
+		if candidate == 0 {
+			break
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wedaly/aretext/internal/pkg/text"
+)
+
+func TestRegexSearchLocatorForward(t *testing.T) {
+	tree, err := text.NewTreeFromString("foo bar foo baz")
+	assert.NoError(t, err)
+
+	loc, err := NewRegexSearchLocator("foo", text.ReadDirectionForward, 1)
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	assert.Equal(t, uint64(8), loc.Locate(state).position)
+}
+
+func TestRegexSearchLocatorBackward(t *testing.T) {
+	tree, err := text.NewTreeFromString("foo bar foo baz")
+	assert.NoError(t, err)
+
+	loc, err := NewRegexSearchLocator("foo", text.ReadDirectionBackward, 1)
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 8}}
+	assert.Equal(t, uint64(0), loc.Locate(state).position)
+}
+
+// TestRegexSearchLocatorNoMatchLeavesCursor covers searching for a pattern
+// that appears fewer times than requested: the cursor must stay put rather
+// than landing on a partial result.
+func TestRegexSearchLocatorNoMatchLeavesCursor(t *testing.T) {
+	tree, err := text.NewTreeFromString("foo bar")
+	assert.NoError(t, err)
+
+	loc, err := NewRegexSearchLocator("foo", text.ReadDirectionForward, 2)
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	assert.Equal(t, uint64(0), loc.Locate(state).position)
+}
+
+// TestRegexSearchLocatorAnchoredOnlyMatchesLineStart covers a "^"-anchored
+// pattern, which must only match at the start of the text or just after a
+// newline, not at an arbitrary position that happens to match unanchored.
+func TestRegexSearchLocatorAnchoredOnlyMatchesLineStart(t *testing.T) {
+	tree, err := text.NewTreeFromString("xfoo\nfoo")
+	assert.NoError(t, err)
+
+	loc, err := NewRegexSearchLocator("^foo", text.ReadDirectionForward, 1)
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 0}}
+	assert.Equal(t, uint64(5), loc.Locate(state).position)
+}
+
+// TestRegexSearchLocatorAnchoredBackward covers a "^"-anchored pattern
+// searched backward, which must apply the same line-start restriction as the
+// forward direction rather than matching at an arbitrary earlier position.
+func TestRegexSearchLocatorAnchoredBackward(t *testing.T) {
+	tree, err := text.NewTreeFromString("xfoo\nfoo")
+	assert.NoError(t, err)
+
+	loc, err := NewRegexSearchLocator("^foo", text.ReadDirectionBackward, 1)
+	assert.NoError(t, err)
+
+	state := &State{tree: tree, cursor: cursorState{position: 8}}
+	assert.Equal(t, uint64(5), loc.Locate(state).position)
+}
@@ -0,0 +1,252 @@
+package exec
+
+import (
+	"fmt"
+	"unicode"
+
+	"github.com/wedaly/aretext/internal/pkg/text"
+	"github.com/wedaly/aretext/internal/pkg/text/segment"
+)
+
+// charClass classifies a grapheme cluster for the purposes of word motions.
+type charClass int
+
+const (
+	charClassWhitespace = charClass(iota)
+	charClassNewline
+	charClassWord
+	charClassPunct
+)
+
+// classifySegment classifies a grapheme cluster's first rune.
+// When bigWord is true, every non-whitespace, non-newline cluster is treated
+// as a single "WORD" class, matching Vim's W/E/B motions; otherwise word
+// characters (letters, digits, underscore) and punctuation are distinct
+// classes, matching Vim's w/e/b motions.
+func classifySegment(seg *segment.Segment, bigWord bool) charClass {
+	if seg.HasNewline() {
+		return charClassNewline
+	}
+	if seg.IsWhitespace() {
+		return charClassWhitespace
+	}
+	if bigWord {
+		return charClassWord
+	}
+
+	runes := seg.Runes()
+	if len(runes) > 0 && isWordRune(runes[0]) {
+		return charClassWord
+	}
+	return charClassPunct
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordLocator implements Vim's w/W (forward) and b/B (backward) motions.
+type wordLocator struct {
+	direction text.ReadDirection
+	count     uint64
+	bigWord   bool
+}
+
+// NewWordLocator builds a locator for the start of the next (or, moving
+// backward, previous) word. bigWord selects Vim's WORD semantics (whitespace
+// delimited) instead of the default word semantics (letters/digits/underscore
+// and punctuation are distinct words).
+func NewWordLocator(direction text.ReadDirection, count uint64, bigWord bool) Locator {
+	if count == 0 {
+		panic("Count must be greater than zero")
+	}
+	return &wordLocator{direction, count, bigWord}
+}
+
+func (loc *wordLocator) String() string {
+	return fmt.Sprintf("WordLocator(%s, %d, %t)", directionString(loc.direction), loc.count, loc.bigWord)
+}
+
+// Locate moves the cursor to the start of the next or previous word, repeated count times.
+func (loc *wordLocator) Locate(state *State) cursorState {
+	pos := state.cursor.position
+	for i := uint64(0); i < loc.count; i++ {
+		if loc.direction == text.ReadDirectionBackward {
+			pos = loc.prevWordStart(state.tree, pos)
+		} else {
+			pos = loc.nextWordStart(state.tree, pos)
+		}
+	}
+	return cursorState{position: pos}
+}
+
+// nextWordStart skips the rest of the current word-class run, then any
+// whitespace, landing on the start of the next non-whitespace class. An empty
+// line is treated as a word of its own, matching Vim.
+func (loc *wordLocator) nextWordStart(tree *text.Tree, pos uint64) uint64 {
+	segmentIter := gcIterForTree(tree, pos, text.ReadDirectionForward)
+
+	seg, eof := nextSegmentOrEof(segmentIter)
+	if eof {
+		return pos
+	}
+	startClass := classifySegment(seg, loc.bigWord)
+	offset := seg.NumRunes()
+
+	if startClass != charClassNewline {
+		for {
+			seg, eof = nextSegmentOrEof(segmentIter)
+			if eof {
+				return pos + offset
+			}
+			if classifySegment(seg, loc.bigWord) != startClass {
+				break
+			}
+			offset += seg.NumRunes()
+		}
+	} else {
+		// The starting segment is itself a whole word (a blank line), already
+		// accounted for in offset, so fetch the next segment fresh instead of
+		// re-entering the skip loop above with it (which would double-count it).
+		seg, eof = nextSegmentOrEof(segmentIter)
+		if eof {
+			return pos + offset
+		}
+	}
+
+	prevNewline := startClass == charClassNewline
+	for classifySegment(seg, loc.bigWord) == charClassWhitespace || classifySegment(seg, loc.bigWord) == charClassNewline {
+		isNewline := classifySegment(seg, loc.bigWord) == charClassNewline
+		if isNewline && prevNewline {
+			// A newline right after another newline is an empty line,
+			// which is a word of its own.
+			return pos + offset
+		}
+		prevNewline = isNewline
+		offset += seg.NumRunes()
+		seg, eof = nextSegmentOrEof(segmentIter)
+		if eof {
+			return pos + offset
+		}
+	}
+
+	return pos + offset
+}
+
+// prevWordStart is the symmetric backward version of nextWordStart: skip any
+// whitespace immediately before the cursor, then skip back to the start of
+// the word-class run before that.
+func (loc *wordLocator) prevWordStart(tree *text.Tree, pos uint64) uint64 {
+	segmentIter := gcIterForTree(tree, pos, text.ReadDirectionBackward)
+
+	seg, eof := nextSegmentOrEof(segmentIter)
+	if eof {
+		return pos
+	}
+	offset := seg.NumRunes()
+
+	for classifySegment(seg, loc.bigWord) == charClassWhitespace || classifySegment(seg, loc.bigWord) == charClassNewline {
+		seg, eof = nextSegmentOrEof(segmentIter)
+		if eof {
+			return pos - offset
+		}
+		offset += seg.NumRunes()
+	}
+
+	wordClass := classifySegment(seg, loc.bigWord)
+
+	for {
+		prevOffset := offset
+		seg, eof = nextSegmentOrEof(segmentIter)
+		if eof {
+			return pos - offset
+		}
+		if classifySegment(seg, loc.bigWord) != wordClass {
+			return pos - prevOffset
+		}
+		offset += seg.NumRunes()
+	}
+}
+
+// wordEndLocator implements Vim's e/E (forward end-of-word) motion.
+type wordEndLocator struct {
+	direction text.ReadDirection
+	count     uint64
+	bigWord   bool
+}
+
+// NewWordEndLocator builds a locator for the last grapheme cluster of the next
+// (or, moving backward, previous) word.
+func NewWordEndLocator(direction text.ReadDirection, count uint64, bigWord bool) Locator {
+	if count == 0 {
+		panic("Count must be greater than zero")
+	}
+	return &wordEndLocator{direction, count, bigWord}
+}
+
+func (loc *wordEndLocator) String() string {
+	return fmt.Sprintf("WordEndLocator(%s, %d, %t)", directionString(loc.direction), loc.count, loc.bigWord)
+}
+
+// Locate moves the cursor to the end of the next or previous word, repeated count times.
+func (loc *wordEndLocator) Locate(state *State) cursorState {
+	pos := state.cursor.position
+	for i := uint64(0); i < loc.count; i++ {
+		pos = loc.wordEnd(state.tree, pos, loc.direction)
+	}
+	return cursorState{position: pos}
+}
+
+// wordEnd always advances at least one grapheme cluster (so repeated `e` makes
+// progress even from the last character of a word), skips any whitespace or
+// blank lines, then advances to the last cluster of the following word-class run.
+func (loc *wordEndLocator) wordEnd(tree *text.Tree, pos uint64, direction text.ReadDirection) uint64 {
+	segmentIter := gcIterForTree(tree, pos, direction)
+
+	seg, eof := nextSegmentOrEof(segmentIter)
+	if eof {
+		return pos
+	}
+	offset := seg.NumRunes()
+
+	if direction == text.ReadDirectionForward {
+		// Reading forward, the first segment is the cluster under the cursor,
+		// so step past it before looking for the word.
+		seg, eof = nextSegmentOrEof(segmentIter)
+		if eof {
+			return pos
+		}
+		offset += seg.NumRunes()
+	}
+
+	for classifySegment(seg, loc.bigWord) == charClassWhitespace || classifySegment(seg, loc.bigWord) == charClassNewline {
+		seg, eof = nextSegmentOrEof(segmentIter)
+		if eof {
+			return applyOffset(pos, offset, direction)
+		}
+		offset += seg.NumRunes()
+	}
+
+	wordClass := classifySegment(seg, loc.bigWord)
+	for {
+		prevOffset := offset
+		segLen := seg.NumRunes()
+		nextSeg, eof := nextSegmentOrEof(segmentIter)
+		if eof || classifySegment(nextSeg, loc.bigWord) != wordClass {
+			if direction == text.ReadDirectionForward {
+				// prevOffset counts through the end of seg; land on seg itself.
+				return pos + prevOffset - segLen
+			}
+			return applyOffset(pos, prevOffset, direction)
+		}
+		offset += nextSeg.NumRunes()
+		seg = nextSeg
+	}
+}
+
+func applyOffset(pos, offset uint64, direction text.ReadDirection) uint64 {
+	if direction == text.ReadDirectionBackward {
+		return pos - offset
+	}
+	return pos + offset
+}
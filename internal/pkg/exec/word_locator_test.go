@@ -0,0 +1,36 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wedaly/aretext/internal/pkg/text"
+)
+
+// TestWordStartAcrossNewline covers w/b crossing a single newline between two
+// words ("foo\nbar"). classifySegment treats a newline as its own class,
+// distinct from whitespace, so the whitespace-skip loops in nextWordStart and
+// prevWordStart must also check for charClassNewline or they stop on the
+// newline itself instead of advancing past it.
+func TestWordStartAcrossNewline(t *testing.T) {
+	tree, err := text.NewTreeFromString("foo\nbar")
+	assert.NoError(t, err)
+
+	loc := &wordLocator{direction: text.ReadDirectionForward, count: 1}
+	assert.Equal(t, uint64(4), loc.nextWordStart(tree, 0))
+
+	loc = &wordLocator{direction: text.ReadDirectionBackward, count: 1}
+	assert.Equal(t, uint64(0), loc.prevWordStart(tree, 4))
+}
+
+// TestWordStartFromBlankLine covers w starting on a blank line itself rather
+// than a word before it ("\nbar", pos=0). The blank line's newline segment is
+// already a complete word on its own (matching Vim), so nextWordStart must
+// not re-count it as part of the whitespace it then skips past.
+func TestWordStartFromBlankLine(t *testing.T) {
+	tree, err := text.NewTreeFromString("\nbar")
+	assert.NoError(t, err)
+
+	loc := &wordLocator{direction: text.ReadDirectionForward, count: 1}
+	assert.Equal(t, uint64(1), loc.nextWordStart(tree, 0))
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -13,8 +15,88 @@ import (
 	"github.com/wedaly/aretext/internal/pkg/syntax/rules"
 )
 
+// grammarsDir holds one `.aretextlex` grammar file per language.
+// Adding a language is a matter of dropping a new grammar file here;
+// it does not require any Go code changes.
+const grammarsDir = "grammars"
+
 func main() {
 	generateTokenizer("JsonTokenizer", rules.JsonRules, "json_tokenizer.go")
+
+	languages, err := generateTokenizersFromGrammars(grammarsDir)
+	if err != nil {
+		log.Fatalf("Error generating tokenizers from %s: %v\n", grammarsDir, err)
+	}
+
+	if err := writeLanguageRegistry(languages, "language_registry.go"); err != nil {
+		log.Fatalf("Error writing language registry: %v\n", err)
+	}
+}
+
+// generateTokenizersFromGrammars walks grammarsDir, generating one `*_tokenizer.go`
+// file per `.aretextlex` grammar file it finds, and returns the file extension ->
+// tokenizer name mapping for the generated language registry.
+func generateTokenizersFromGrammars(dir string) (map[string]string, error) {
+	languages := make(map[string]string)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Not every checkout has grammar files yet; the hardcoded JSON
+			// tokenizer above still gets generated.
+			return languages, nil
+		}
+		return nil, errors.Wrapf(err, "os.ReadDir")
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".aretextlex") {
+			continue
+		}
+
+		grammarPath := filepath.Join(dir, entry.Name())
+		grammar, err := parser.ParseGrammarFile(grammarPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parser.ParseGrammarFile(%s)", grammarPath)
+		}
+
+		ext := strings.TrimSuffix(entry.Name(), ".aretextlex")
+		tokenizerName := strings.Title(ext) + "Tokenizer"
+		outputPath := ext + "_tokenizer.go"
+
+		tokenizerRules, err := grammarRulesToTokenizerRules(grammar)
+		if err != nil {
+			return nil, errors.Wrapf(err, "grammarRulesToTokenizerRules(%s)", grammarPath)
+		}
+
+		generateTokenizer(tokenizerName, tokenizerRules, outputPath)
+		languages["."+ext] = tokenizerName
+	}
+
+	return languages, nil
+}
+
+// grammarRulesToTokenizerRules converts a grammar's rules into the flat list
+// GenerateTokenizer compiles into a single DFA. GenerateTokenizer doesn't yet
+// branch on PushMode/PopMode/Channel (see the GrammarRule doc comment), so a
+// rule that uses one of them would silently tokenize as if it hadn't; this
+// rejects the grammar instead of generating a tokenizer that doesn't do what
+// the grammar file says.
+func grammarRulesToTokenizerRules(grammar *parser.Grammar) ([]parser.TokenizerRule, error) {
+	var rules []parser.TokenizerRule
+	for _, r := range grammar.Rules {
+		if r.Skip {
+			continue
+		}
+		if r.PushMode != "" || r.PopMode || r.Channel != "" {
+			return nil, errors.Errorf("rule %q uses pushMode/popMode/channel, which GenerateTokenizer does not yet support", r.Name)
+		}
+		rules = append(rules, parser.TokenizerRule{
+			Regexp:    r.Regexp,
+			TokenRole: r.TokenRole,
+		})
+	}
+	return rules, nil
 }
 
 func generateTokenizer(tokenizerName string, tokenizerRules []parser.TokenizerRule, outputPath string) {
@@ -74,3 +156,35 @@ func init() {
 		"Tokenizer":     tokenizer,
 	})
 }
+
+// writeLanguageRegistry generates a file mapping file extensions to their tokenizer,
+// so that adding a language by dropping in a grammar file doesn't require touching
+// any other Go code.
+func writeLanguageRegistry(languages map[string]string, outputPath string) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return errors.Wrapf(err, "os.Create")
+	}
+	defer f.Close()
+
+	tmplStr := `// This file is generated by gen_tokenizers.go.  DO NOT EDIT.
+package syntax
+
+import "github.com/wedaly/aretext/internal/pkg/syntax/parser"
+
+// LanguageRegistry maps a file extension (including the leading dot) to the
+// tokenizer generated for that language's grammar file.
+var LanguageRegistry = map[string]*parser.Tokenizer{
+	{{ range $ext, $tokenizerName := . }}
+	{{ printf "%q" $ext }}: {{ $tokenizerName }},
+	{{ end }}
+}
+`
+
+	tmpl, err := template.New("languageRegistry").Parse(tmplStr)
+	if err != nil {
+		return errors.Wrapf(err, "template.New")
+	}
+
+	return tmpl.Execute(f, languages)
+}
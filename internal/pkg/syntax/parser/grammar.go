@@ -0,0 +1,290 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GrammarRule is a single named lexer rule parsed from a grammar file.
+// A rule with Skip set is matched during tokenization but produces no token,
+// which is how grammar files express whitespace/comment rules that the ANTLR4
+// lexer convention spells `-> skip`. PushMode, PopMode, and Channel mirror
+// ANTLR4's other mode-stack lexer actions (`-> pushMode(X)`, `-> popMode`,
+// `-> channel(X)`); parsing accepts and preserves them on the rule, but
+// GenerateTokenizer's single flat DFA does not yet branch on them, so they
+// have no effect on tokenization until that's built.
+type GrammarRule struct {
+	Name      string
+	Regexp    string
+	TokenRole TokenRole
+	Skip      bool
+	PushMode  string // target mode name, or "" if this rule doesn't push a mode
+	PopMode   bool
+	Channel   string // target channel name, or "" if this rule doesn't redirect
+}
+
+// Grammar is the parsed form of a `.aretextlex` grammar file: an ordered list
+// of lexer rules with fragment references already resolved to their bodies.
+type Grammar struct {
+	Rules []GrammarRule
+}
+
+var fragmentRefRegexp = regexp.MustCompile(`<([A-Za-z_][A-Za-z0-9_]*)>`)
+
+// ParseGrammarFile reads a grammar file and resolves it to an ordered list of rules.
+// Fragment rules (declared with a `fragment` prefix) are inlined into every rule that
+// references them via `<FragmentName>` and are themselves omitted from the output,
+// mirroring how ANTLR4 lexer grammars treat fragments as non-emitting helper rules.
+func ParseGrammarFile(path string) (*Grammar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "os.Open")
+	}
+	defer f.Close()
+
+	fragments := make(map[string]string)
+	var rawRules []rawGrammarRule
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		rule, err := parseGrammarLine(line)
+		if err != nil {
+			return nil, grammarErrorAtLine(path, lineNum, err)
+		}
+
+		if rule.isFragment {
+			fragments[rule.name] = rule.body
+			continue
+		}
+
+		rawRules = append(rawRules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "scanner.Err")
+	}
+
+	grammar := &Grammar{}
+	for _, rule := range rawRules {
+		resolvedBody, err := resolveFragments(rule.body, fragments)
+		if err != nil {
+			return nil, grammarErrorAtLine(path, rule.lineNum, err)
+		}
+
+		if err := validateRuleBody(resolvedBody); err != nil {
+			return nil, grammarErrorAtLine(path, rule.lineNum, err)
+		}
+
+		grammar.Rules = append(grammar.Rules, GrammarRule{
+			Name:      rule.name,
+			Regexp:    resolvedBody,
+			TokenRole: rule.tokenRole,
+			Skip:      rule.skip,
+			PushMode:  rule.pushMode,
+			PopMode:   rule.popMode,
+			Channel:   rule.channel,
+		})
+	}
+
+	return grammar, nil
+}
+
+// rawGrammarRule is a single parsed line from a grammar file, before fragment resolution.
+type rawGrammarRule struct {
+	lineNum    int
+	isFragment bool
+	name       string
+	body       string
+	tokenRole  TokenRole
+	skip       bool
+	pushMode   string
+	popMode    bool
+	channel    string
+}
+
+// parseGrammarLine parses a single non-blank, non-comment line of a grammar file.
+// A line has the form `Name: regexBody ;` or `Name: regexBody -> action, action ;`,
+// optionally prefixed with `fragment` to declare a non-emitting helper rule.
+// Recognized actions are `skip`, `pushMode(ModeName)`, `popMode`, `channel(ChannelName)`,
+// and `role(RoleName)` (overriding the TokenRole that tokenRoleForRuleName would
+// otherwise infer from the rule's name); multiple actions are comma-separated,
+// on the model of ANTLR4 lexer commands.
+func parseGrammarLine(line string) (rawGrammarRule, error) {
+	if !strings.HasSuffix(line, ";") {
+		return rawGrammarRule{}, errors.New("expected rule to end with ';'")
+	}
+	line = strings.TrimSuffix(line, ";")
+
+	isFragment := false
+	if strings.HasPrefix(line, "fragment ") {
+		isFragment = true
+		line = strings.TrimPrefix(line, "fragment ")
+	}
+
+	nameAndRest := strings.SplitN(line, ":", 2)
+	if len(nameAndRest) != 2 {
+		return rawGrammarRule{}, errors.New("expected 'Name: body'")
+	}
+	name := strings.TrimSpace(nameAndRest[0])
+	rest := strings.TrimSpace(nameAndRest[1])
+
+	rule := rawGrammarRule{
+		isFragment: isFragment,
+		name:       name,
+		tokenRole:  tokenRoleForRuleName(name),
+	}
+
+	body := rest
+	if idx := strings.Index(rest, "->"); idx >= 0 {
+		body = strings.TrimSpace(rest[:idx])
+		for _, action := range strings.Split(rest[idx+len("->"):], ",") {
+			if err := applyLexerAction(strings.TrimSpace(action), &rule); err != nil {
+				return rawGrammarRule{}, err
+			}
+		}
+	}
+	rule.body = body
+
+	return rule, nil
+}
+
+// applyLexerAction parses a single `->` clause action and records its effect on rule.
+func applyLexerAction(action string, rule *rawGrammarRule) error {
+	switch {
+	case action == "skip":
+		rule.skip = true
+	case action == "popMode":
+		rule.popMode = true
+	case strings.HasPrefix(action, "pushMode(") && strings.HasSuffix(action, ")"):
+		rule.pushMode = strings.TrimSuffix(strings.TrimPrefix(action, "pushMode("), ")")
+	case strings.HasPrefix(action, "channel(") && strings.HasSuffix(action, ")"):
+		rule.channel = strings.TrimSuffix(strings.TrimPrefix(action, "channel("), ")")
+	case strings.HasPrefix(action, "role(") && strings.HasSuffix(action, ")"):
+		roleName := strings.TrimSuffix(strings.TrimPrefix(action, "role("), ")")
+		role, ok := tokenRoleByName[strings.ToLower(roleName)]
+		if !ok {
+			return errors.Errorf("unknown token role %q", roleName)
+		}
+		rule.tokenRole = role
+	default:
+		return errors.Errorf("unsupported lexer action %q", action)
+	}
+	return nil
+}
+
+// resolveFragments inlines every `<FragmentName>` reference in body with the
+// fragment's regex, detecting self-referential fragments that would otherwise
+// recurse forever.
+func resolveFragments(body string, fragments map[string]string) (string, error) {
+	const maxDepth = 32
+	for i := 0; i < maxDepth; i++ {
+		if !fragmentRefRegexp.MatchString(body) {
+			return body, nil
+		}
+
+		var resolveErr error
+		body = fragmentRefRegexp.ReplaceAllStringFunc(body, func(ref string) string {
+			name := fragmentRefRegexp.FindStringSubmatch(ref)[1]
+			frag, ok := fragments[name]
+			if !ok {
+				resolveErr = errors.Errorf("undefined fragment %q", name)
+				return ref
+			}
+			return "(" + frag + ")"
+		})
+		if resolveErr != nil {
+			return "", resolveErr
+		}
+	}
+
+	return "", errors.New("fragment reference cycle exceeded max depth")
+}
+
+// validateRuleBody rejects rule bodies that GenerateTokenizer cannot compile into a DFA:
+// bodies that don't parse as a regexp, and zero-width matches (which would loop forever
+// at a fixed position). It does not look for fragment reference cycles; resolveFragments
+// rejects those before a body reaches this check.
+func validateRuleBody(body string) error {
+	re, err := ParseRegexp(body)
+	if err != nil {
+		return errors.Wrapf(err, "invalid regexp")
+	}
+
+	if regexpMatchesEmptyString(re) {
+		return errors.New("rule must not match the empty string")
+	}
+
+	return nil
+}
+
+// regexpMatchesEmptyString reports whether re can match the empty string.
+// Regexp's only method is CompileNfa, which has no way to query acceptance
+// short of simulating the NFA against input, so this walks the same AST
+// shape CompileNfa does and decides nullability structurally instead.
+func regexpMatchesEmptyString(re Regexp) bool {
+	switch re := re.(type) {
+	case regexpEmpty, regexpStartOfText, regexpEndOfText:
+		return true
+	case regexpChar, regexpCharClass:
+		return false
+	case regexpConcat:
+		return regexpMatchesEmptyString(re.left) && regexpMatchesEmptyString(re.right)
+	case regexpUnion:
+		return regexpMatchesEmptyString(re.left) || regexpMatchesEmptyString(re.right)
+	case regexpStar:
+		return true
+	case regexpParenExpr:
+		return regexpMatchesEmptyString(re.child)
+	default:
+		return false
+	}
+}
+
+// grammarErrorAtLine annotates an error with the grammar file and line number where
+// it occurred, on the model of the text/template parser's actionLine position tracking.
+func grammarErrorAtLine(path string, lineNum int, err error) error {
+	return fmt.Errorf("%s:%d: %w", path, lineNum, err)
+}
+
+// tokenRoleForRuleName derives a generated rule's TokenRole from its capitalized name,
+// e.g. `StringLiteral` -> TokenRoleString, falling back to TokenRoleNone for rule names
+// that don't match a well-known role. This is only a convenience default: a rule for
+// anything this heuristic doesn't cover (identifiers, operators, punctuation, ...) can
+// still get the role it needs via an explicit `-> role(Name)` action, which is the
+// mechanism adding a language is actually expected to use once the name no longer says it.
+func tokenRoleForRuleName(name string) TokenRole {
+	switch strings.ToLower(name) {
+	case "string", "stringliteral":
+		return TokenRoleString
+	case "comment":
+		return TokenRoleComment
+	case "number", "numberliteral":
+		return TokenRoleNumber
+	case "keyword":
+		return TokenRoleKeyword
+	default:
+		return TokenRoleNone
+	}
+}
+
+// tokenRoleByName maps the lowercase name used in a grammar file's `-> role(Name)`
+// action to the TokenRole it selects, covering every role tokenRoleForRuleName can
+// infer automatically plus TokenRoleNone for explicitly opting out.
+var tokenRoleByName = map[string]TokenRole{
+	"none":    TokenRoleNone,
+	"string":  TokenRoleString,
+	"comment": TokenRoleComment,
+	"number":  TokenRoleNumber,
+	"keyword": TokenRoleKeyword,
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGrammarLineActions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		line         string
+		expectErr    bool
+		expectSkip   bool
+		expectPush   string
+		expectPop    bool
+		expectChan   string
+		expectedRole TokenRole
+	}{
+		{
+			name:       "skip",
+			line:       `Whitespace: [ \t]+ -> skip ;`,
+			expectSkip: true,
+		},
+		{
+			name:       "pushMode",
+			line:       `StringStart: '"' -> pushMode(InString) ;`,
+			expectPush: "InString",
+		},
+		{
+			name:      "popMode",
+			line:      `StringEnd: '"' -> popMode ;`,
+			expectPop: true,
+		},
+		{
+			name:       "channel",
+			line:       `LineComment: [/][/][^\n]* -> channel(HIDDEN) ;`,
+			expectChan: "HIDDEN",
+		},
+		{
+			name:         "role overrides the name-based default",
+			line:         `Op: [+] -> role(keyword) ;`,
+			expectedRole: TokenRoleKeyword,
+		},
+		{
+			name:       "multiple comma-separated actions",
+			line:       `StringStart: '"' -> pushMode(InString), channel(HIDDEN) ;`,
+			expectPush: "InString",
+			expectChan: "HIDDEN",
+		},
+		{
+			name:      "unknown role is an error",
+			line:      `Op: [+] -> role(bogus) ;`,
+			expectErr: true,
+		},
+		{
+			name:      "unsupported action is still an error",
+			line:      `Op: [+] -> frobnicate ;`,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := parseGrammarLine(tc.line)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectSkip, rule.skip)
+			assert.Equal(t, tc.expectPush, rule.pushMode)
+			assert.Equal(t, tc.expectPop, rule.popMode)
+			assert.Equal(t, tc.expectChan, rule.channel)
+			if tc.expectedRole != TokenRoleNone {
+				assert.Equal(t, tc.expectedRole, rule.tokenRole)
+			}
+		})
+	}
+}
+
+func TestValidateRuleBodyRejectsEmptyMatch(t *testing.T) {
+	testCases := []struct {
+		name      string
+		body      string
+		expectErr bool
+	}{
+		{name: "non-empty literal", body: "abc", expectErr: false},
+		{name: "plus requires at least one", body: "a+", expectErr: false},
+		{name: "star accepts the empty string", body: "a*", expectErr: true},
+		{name: "optional accepts the empty string", body: "a?", expectErr: true},
+		{name: "bounded repetition with zero minimum", body: "a{0,3}", expectErr: true},
+		{name: "bounded repetition with nonzero minimum", body: "a{1,3}", expectErr: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRuleBody(tc.body)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package parser
+
+// CharSet is a set of runes represented as a sparse list of inclusive ranges.
+type CharSet struct {
+	ranges []runeRange
+}
+
+// Contains reports whether r is a member of the set.
+func (cs *CharSet) Contains(r rune) bool {
+	if cs == nil {
+		return false
+	}
+	for _, rg := range cs.ranges {
+		if r >= rg.lo && r <= rg.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefix describes what can appear at the start of any match of a regexp,
+// computed once at compile time so a search over a large buffer doesn't need
+// to invoke the full NFA step function at every position.
+type Prefix struct {
+	// Literal, if non-empty, is a fixed sequence of runes that every match
+	// must start with (e.g. the regexp "func " has Literal == []rune("func ")).
+	Literal []rune
+
+	// Set, if non-nil, holds every rune that could be the first rune of a match.
+	// It is nil if the regexp is nullable (can match the empty string) or the
+	// analysis couldn't bound the first character (e.g. a negated class or `.`).
+	Set *CharSet
+
+	// Anchored is true if every match must start at the beginning of the text
+	// or immediately after a newline (the regexp begins with ^).
+	Anchored bool
+}
+
+// ComputePrefix analyzes a regexp's AST and returns the Prefix that a search
+// can use to skip positions that can't possibly start a match, or nil if no
+// useful prefix could be determined.
+func ComputePrefix(r Regexp) *Prefix {
+	info := computeFirstChars(r)
+
+	if literal := computeLiteralPrefix(r); len(literal) > 0 {
+		return &Prefix{Literal: literal, Anchored: info.anchored}
+	}
+
+	if info.nullable || info.unbounded || len(info.ranges) == 0 {
+		if info.anchored {
+			return &Prefix{Anchored: true}
+		}
+		return nil
+	}
+
+	return &Prefix{Set: &CharSet{ranges: info.ranges}, Anchored: info.anchored}
+}
+
+// firstCharInfo is the result of analyzing a regexp subtree: the set of runes
+// it could start with, whether it can match the empty string, whether its
+// first-char set couldn't be bounded (so scanning can't use it to skip ahead),
+// and whether every match is anchored to the start of a line.
+type firstCharInfo struct {
+	ranges    []runeRange
+	nullable  bool
+	unbounded bool
+	anchored  bool
+}
+
+func computeFirstChars(r Regexp) firstCharInfo {
+	switch r := r.(type) {
+	case regexpEmpty:
+		return firstCharInfo{nullable: true}
+
+	case regexpChar:
+		return firstCharInfo{ranges: []runeRange{{r.char, r.char}}}
+
+	case regexpCharClass:
+		if r.negated {
+			// A negated class can match almost any rune; representing its
+			// complement compactly isn't worth it, so treat it as unbounded.
+			return firstCharInfo{unbounded: true}
+		}
+		ranges := append([]runeRange{}, r.ranges...)
+		for _, c := range r.chars {
+			ranges = append(ranges, runeRange{lo: c, hi: c})
+		}
+		return firstCharInfo{ranges: ranges}
+
+	case regexpConcat:
+		left := computeFirstChars(r.left)
+		if !left.nullable {
+			return left
+		}
+		right := computeFirstChars(r.right)
+		return firstCharInfo{
+			ranges:    append(left.ranges, right.ranges...),
+			nullable:  right.nullable,
+			unbounded: left.unbounded || right.unbounded,
+			anchored:  left.anchored,
+		}
+
+	case regexpUnion:
+		left := computeFirstChars(r.left)
+		right := computeFirstChars(r.right)
+		return firstCharInfo{
+			ranges:    append(left.ranges, right.ranges...),
+			nullable:  left.nullable || right.nullable,
+			unbounded: left.unbounded || right.unbounded,
+			anchored:  left.anchored && right.anchored,
+		}
+
+	case regexpStar:
+		child := computeFirstChars(r.child)
+		return firstCharInfo{ranges: child.ranges, nullable: true, unbounded: child.unbounded}
+
+	case regexpParenExpr:
+		return computeFirstChars(r.child)
+
+	case regexpStartOfText:
+		return firstCharInfo{nullable: true, anchored: true}
+
+	case regexpEndOfText:
+		return firstCharInfo{nullable: true}
+
+	default:
+		return firstCharInfo{unbounded: true}
+	}
+}
+
+// flattenConcat returns a regexp's concatenated operands in left-to-right order.
+func flattenConcat(r Regexp) []Regexp {
+	if concat, ok := r.(regexpConcat); ok {
+		return append(flattenConcat(concat.left), flattenConcat(concat.right)...)
+	}
+	return []Regexp{r}
+}
+
+// computeLiteralPrefix returns the longest run of single-rune matches at the
+// start of r's concatenation chain. A leading ^ consumes no input, so it is
+// skipped rather than ending the run (Prefix.Anchored records it instead).
+func computeLiteralPrefix(r Regexp) []rune {
+	var literal []rune
+	for i, atom := range flattenConcat(r) {
+		if _, ok := atom.(regexpStartOfText); ok && i == 0 {
+			continue
+		}
+		if paren, ok := atom.(regexpParenExpr); ok {
+			atom = paren.child
+		}
+		ch, ok := atom.(regexpChar)
+		if !ok {
+			break
+		}
+		literal = append(literal, ch.char)
+	}
+	return literal
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputePrefixLiteral(t *testing.T) {
+	r, err := ParseRegexp("func ")
+	assert.NoError(t, err)
+
+	prefix := ComputePrefix(r)
+	assert.Equal(t, []rune("func "), prefix.Literal)
+	assert.False(t, prefix.Anchored)
+}
+
+func TestComputePrefixAnchoredLiteral(t *testing.T) {
+	r, err := ParseRegexp("^foo")
+	assert.NoError(t, err)
+
+	prefix := ComputePrefix(r)
+	assert.Equal(t, []rune("foo"), prefix.Literal)
+	assert.True(t, prefix.Anchored)
+}
+
+func TestComputePrefixCharSet(t *testing.T) {
+	r, err := ParseRegexp("[a-z]foo")
+	assert.NoError(t, err)
+
+	prefix := ComputePrefix(r)
+	assert.NotNil(t, prefix.Set)
+	assert.True(t, prefix.Set.Contains('m'))
+	assert.False(t, prefix.Set.Contains('5'))
+}
+
+// TestComputePrefixUnboundedIsNil covers a pattern starting with a negated
+// class, whose first-char set can't be bounded: ComputePrefix must return
+// nil instead of a Prefix that would wrongly filter out real matches.
+func TestComputePrefixUnboundedIsNil(t *testing.T) {
+	r, err := ParseRegexp("[^a]foo")
+	assert.NoError(t, err)
+
+	prefix := ComputePrefix(r)
+	assert.Nil(t, prefix)
+}
@@ -1,6 +1,18 @@
 package parser
 
-import "errors"
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// Named parse errors, on the model of the historical Go regexp package,
+// so callers can distinguish one kind of bad pattern from another instead
+// of pattern-matching on an error string.
+var (
+	ErrBadRange          = errors.New("Invalid character class range")
+	ErrBadClosure        = errors.New("Invalid repetition count")
+	ErrTrailingBackslash = errors.New("Trailing backslash at end of regular expression")
+)
 
 // Regexp represents a regular expression.
 type Regexp interface {
@@ -52,26 +64,55 @@ func (r regexpParenExpr) CompileNfa() *Nfa {
 	return r.child.CompileNfa()
 }
 
-// regexpChar represents a character match in the regular expression.
+// regexpChar represents a single rune match in the regular expression.
 type regexpChar struct {
-	char byte
+	char rune
 }
 
 func (r regexpChar) CompileNfa() *Nfa {
-	return NfaForChars([]byte{r.char})
+	return NfaForChars([]rune{r.char})
 }
 
-// regexpCharClass represents a character class.
+// runeRange represents an inclusive range of runes, as in the character class range a-z.
+type runeRange struct {
+	lo, hi rune
+}
+
+// regexpCharClass represents a character class, e.g. [a-z], [^0-9], or a Perl
+// shorthand class like \w.
 type regexpCharClass struct {
 	negated bool
-	chars   []byte
+	chars   []rune
+	ranges  []runeRange
 }
 
 func (r regexpCharClass) CompileNfa() *Nfa {
+	chars := expandCharClass(r.chars, r.ranges)
 	if r.negated {
-		return NfaForNegatedChars(r.chars)
+		return NfaForNegatedChars(chars)
+	}
+	return NfaForChars(chars)
+}
+
+// maxCharClassRangeSize caps the number of runes a single character class
+// range (e.g. a-z) expands into. Unlike the historical Go regexp package this
+// parser models itself on, classes operate on runes rather than bytes, so a
+// range spanning all of Unicode (U+0000 to U+10FFFF) would otherwise expand to
+// over a million runes in memory. parseCharacterClass rejects oversized ranges with
+// ErrBadRange before a regexpCharClass is ever constructed, so by the time
+// expandCharClass runs every range is already within bounds.
+const maxCharClassRangeSize = 1 << 16
+
+// expandCharClass flattens a character class's individual chars and ranges
+// into a single slice of runes.
+func expandCharClass(chars []rune, ranges []runeRange) []rune {
+	result := append([]rune{}, chars...)
+	for _, rg := range ranges {
+		for c := rg.lo; c <= rg.hi; c++ {
+			result = append(result, c)
+		}
 	}
-	return NfaForChars(r.chars)
+	return result
 }
 
 // regexpStartOfText represents the start-of-text pattern (^)
@@ -88,6 +129,41 @@ func (r regexpEndOfText) CompileNfa() *Nfa {
 	return NfaForEndOfText()
 }
 
+// perlGroups maps each lowercase Perl shorthand class letter to the rune ranges
+// it matches, on the model of the historical Go regexp package's perl_groups table.
+var perlGroups = map[rune][]runeRange{
+	'd': {{'0', '9'}},
+	's': {{' ', ' '}, {'\t', '\t'}, {'\n', '\n'}, {'\r', '\r'}, {'\f', '\f'}, {'\v', '\v'}},
+	'w': {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}, {'_', '_'}},
+}
+
+// perlGroupRegexp returns the Regexp for a Perl shorthand class escape
+// (\d, \D, \w, \W, \s, \S), or ok=false if c names no such class.
+func perlGroupRegexp(c rune) (regexp Regexp, ok bool) {
+	lower, negated := c, false
+	switch c {
+	case 'D', 'W', 'S':
+		lower, negated = c+('d'-'D'), true
+	}
+
+	ranges, ok := perlGroups[lower]
+	if !ok {
+		return nil, false
+	}
+
+	return regexpCharClass{negated: negated, ranges: ranges}, true
+}
+
+// simpleCharEscapes maps single-letter escapes to the rune they represent.
+var simpleCharEscapes = map[rune]rune{
+	'n': '\n',
+	'r': '\r',
+	't': '\t',
+	'f': '\f',
+	'v': '\v',
+	'a': '\a',
+}
+
 // ParseRegexp parses a regular expression string.
 func ParseRegexp(s string) (Regexp, error) {
 	regexp, _, err := parseRegexp(s, 0, false)
@@ -112,17 +188,7 @@ func parseRegexp(s string, pos int, inParen bool) (Regexp, int, error) {
 				return nil, 0, errors.New("Expected closing paren")
 			}
 
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = regexpParenExpr{child: nextRegexp}
-			} else {
-				regexp = regexpConcat{
-					left: regexp,
-					right: regexpParenExpr{
-						child: nextRegexp,
-					},
-				}
-			}
-
+			regexp = appendRegexp(regexp, regexpParenExpr{child: nextRegexp})
 			pos = newPos + 1
 
 		case ')':
@@ -143,68 +209,56 @@ func parseRegexp(s string, pos int, inParen bool) (Regexp, int, error) {
 			pos = newPos
 
 		case '*':
-			if _, ok := regexp.(regexpEmpty); ok {
+			child, ok := lastRegexp(regexp)
+			if !ok {
 				return nil, 0, errors.New("Expected characters before star")
-			} else if concat, ok := regexp.(regexpConcat); ok {
-				regexp = regexpConcat{
-					left:  concat.left,
-					right: regexpStar{child: concat.right},
-				}
-			} else {
-				regexp = regexpStar{child: regexp}
 			}
+			regexp = replaceLastRegexp(regexp, regexpStar{child: child})
 			pos++
 
 		case '+':
-			if _, ok := regexp.(regexpEmpty); ok {
+			child, ok := lastRegexp(regexp)
+			if !ok {
 				return nil, 0, errors.New("Expected characters before plus")
-			} else if concat, ok := regexp.(regexpConcat); ok {
-				regexp = regexpConcat{
-					left: concat.left,
-					right: regexpConcat{
-						left:  concat.right,
-						right: regexpStar{child: concat.right},
-					},
-				}
-			} else {
-				regexp = regexpConcat{
-					left:  regexp,
-					right: regexpStar{child: regexp},
-				}
 			}
+			regexp = replaceLastRegexp(regexp, regexpConcat{left: child, right: regexpStar{child: child}})
 			pos++
 
 		case '?':
-			if _, ok := regexp.(regexpEmpty); ok {
+			child, ok := lastRegexp(regexp)
+			if !ok {
 				return nil, 0, errors.New("Expected characters before question mark")
-			} else if concat, ok := regexp.(regexpConcat); ok {
-				regexp = regexpConcat{
-					left: concat.left,
-					right: regexpUnion{
-						left:  regexpEmpty{},
-						right: concat.right,
-					},
-				}
-			} else {
-				regexp = regexpUnion{
-					left:  regexpEmpty{},
-					right: regexp,
-				}
 			}
+			regexp = replaceLastRegexp(regexp, regexpUnion{left: regexpEmpty{}, right: child})
 			pos++
 
+		case '{':
+			matched := false
+			if child, ok := lastRegexp(regexp); ok {
+				repeated, newPos, err := parseBoundedRepetition(s, pos, child)
+				if err == nil {
+					regexp = replaceLastRegexp(regexp, repeated)
+					pos = newPos
+					matched = true
+				} else if err != errNotRepetition {
+					// A well-formed repetition whose count exceeds maxRepeatCount.
+					return nil, 0, err
+				}
+			}
+			if !matched {
+				// Not a valid {n}/{n,}/{n,m} quantifier (or nothing precedes
+				// it to repeat): match '{' literally, as Go's regexp/RE2 and
+				// PCRE do for a brace that isn't a well-formed repetition.
+				regexp = appendRegexp(regexp, regexpChar{char: '{'})
+				pos++
+			}
+
 		case '\\':
 			nextRegexp, newPos, err := parseEscapeSequence(s, pos)
 			if err != nil {
 				return nil, 0, err
 			}
-
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = nextRegexp
-			} else {
-				regexp = regexpConcat{left: regexp, right: nextRegexp}
-			}
-
+			regexp = appendRegexp(regexp, nextRegexp)
 			pos = newPos
 
 		case '[':
@@ -212,62 +266,202 @@ func parseRegexp(s string, pos int, inParen bool) (Regexp, int, error) {
 			if err != nil {
 				return nil, 0, err
 			}
-
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = nextRegexp
-			} else {
-				regexp = regexpConcat{left: regexp, right: nextRegexp}
-			}
+			regexp = appendRegexp(regexp, nextRegexp)
 			pos = newPos
 
 		case '.':
-			// Negation of no characters is equivalent to accepting every character.
-			nextRegexp := regexpCharClass{negated: true}
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = nextRegexp
-			} else {
-				regexp = regexpConcat{left: regexp, right: nextRegexp}
-			}
+			// Matches any rune except newline: negate a class containing only '\n'.
+			regexp = appendRegexp(regexp, regexpCharClass{negated: true, chars: []rune{'\n'}})
 			pos++
 
 		case '^':
-			nextRegexp := regexpStartOfText{}
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = nextRegexp
-			} else {
-				regexp = regexpConcat{left: regexp, right: nextRegexp}
-			}
+			regexp = appendRegexp(regexp, regexpStartOfText{})
 			pos++
 
 		case '$':
-			nextRegexp := regexpEndOfText{}
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = nextRegexp
-			} else {
-				regexp = regexpConcat{left: regexp, right: nextRegexp}
-			}
+			regexp = appendRegexp(regexp, regexpEndOfText{})
 			pos++
 
 		default:
-			nextRegexp := regexpChar{char: s[pos]}
-			if _, ok := regexp.(regexpEmpty); ok {
-				regexp = nextRegexp
-			} else {
-				regexp = regexpConcat{left: regexp, right: nextRegexp}
-			}
-			pos++
+			r, width := utf8.DecodeRuneInString(s[pos:])
+			regexp = appendRegexp(regexp, regexpChar{char: r})
+			pos += width
 		}
 	}
 	return regexp, pos, nil
 }
 
+// appendRegexp concatenates next onto the end of regexp, or returns next
+// unchanged if regexp is still the empty-string placeholder.
+func appendRegexp(regexp Regexp, next Regexp) Regexp {
+	if _, ok := regexp.(regexpEmpty); ok {
+		return next
+	}
+	return regexpConcat{left: regexp, right: next}
+}
+
+// lastRegexp returns the operand that a trailing closure operator
+// (*, +, ?, {n,m}) applies to: the right side of a concatenation, or the
+// whole expression if it isn't a concatenation.
+func lastRegexp(regexp Regexp) (Regexp, bool) {
+	if _, ok := regexp.(regexpEmpty); ok {
+		return nil, false
+	}
+	if concat, ok := regexp.(regexpConcat); ok {
+		return concat.right, true
+	}
+	return regexp, true
+}
+
+// replaceLastRegexp replaces the operand a closure operator applies to (see
+// lastRegexp) with next.
+func replaceLastRegexp(regexp Regexp, next Regexp) Regexp {
+	if concat, ok := regexp.(regexpConcat); ok {
+		return regexpConcat{left: concat.left, right: next}
+	}
+	return next
+}
+
 func parseEscapeSequence(s string, pos int) (Regexp, int, error) {
-	if pos+1 >= len(s) {
-		return nil, 0, errors.New("Invalid escape sequence")
+	// Consume '\'
+	pos++
+	if pos >= len(s) {
+		return nil, 0, ErrTrailingBackslash
+	}
+
+	c, width := utf8.DecodeRuneInString(s[pos:])
+	newPos := pos + width
+
+	if regexp, ok := perlGroupRegexp(c); ok {
+		return regexp, newPos, nil
+	}
+
+	if escaped, ok := simpleCharEscapes[c]; ok {
+		return regexpChar{char: escaped}, newPos, nil
+	}
+
+	// Any other escaped character (including regex metacharacters like \[, \],
+	// \-, \^, \\, \., \*) is matched literally.
+	return regexpChar{char: c}, newPos, nil
+}
+
+// errNotRepetition is returned by parseBoundedRepetition when the text after
+// '{' isn't a well-formed {n}, {n,}, or {n,m}; the brace is then matched literally.
+var errNotRepetition = errors.New("Not a bounded repetition")
+
+// parseBoundedRepetition parses a bounded repetition `{n}`, `{n,}`, or `{n,m}`
+// starting at s[pos] == '{', desugaring it into concatenations of child.
+// It returns errNotRepetition if the braces don't hold well-formed bounds, and
+// ErrBadClosure if the bounds exceed maxRepeatCount.
+func parseBoundedRepetition(s string, pos int, child Regexp) (Regexp, int, error) {
+	closeIdx := -1
+	for i := pos + 1; i < len(s); i++ {
+		if s[i] == '}' {
+			closeIdx = i
+			break
+		}
+	}
+	if closeIdx < 0 {
+		return nil, 0, errNotRepetition
+	}
+
+	minCount, maxCount, ok := parseRepetitionBounds(s[pos+1 : closeIdx])
+	if !ok {
+		return nil, 0, errNotRepetition
+	}
+
+	regexp, err := desugarBoundedRepetition(child, minCount, maxCount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return regexp, closeIdx + 1, nil
+}
+
+// parseRepetitionBounds parses the body of a {n}, {n,}, or {n,m} repetition.
+// maxCount of -1 means unbounded.
+func parseRepetitionBounds(body string) (minCount, maxCount int, ok bool) {
+	commaIdx := -1
+	for i, c := range body {
+		if c == ',' {
+			commaIdx = i
+			break
+		}
+	}
+
+	if commaIdx < 0 {
+		n, ok := parseUint(body)
+		return n, n, ok
+	}
+
+	n, ok := parseUint(body[:commaIdx])
+	if !ok {
+		return 0, 0, false
+	}
+
+	rest := body[commaIdx+1:]
+	if rest == "" {
+		return n, -1, true
 	}
 
-	c := s[pos+1]
-	return regexpChar{char: c}, pos + 2, nil
+	m, ok := parseUint(rest)
+	if !ok || m < n {
+		return 0, 0, false
+	}
+	return n, m, true
+}
+
+func parseUint(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// maxRepeatCount caps the bounds accepted by {n}, {n,}, and {n,m}, on the
+// model of the historical Go regexp package's own maxRepeat limit: without a
+// cap, a pattern like `x{100000000}` desugars into a concatenation with one
+// node per repetition and can hang or exhaust memory compiling a
+// user-supplied search pattern.
+const maxRepeatCount = 1000
+
+// desugarBoundedRepetition expands {n}, {n,}, or {n,m} into concatenations and,
+// for an unbounded or optional tail, stars/unions of child.
+func desugarBoundedRepetition(child Regexp, minCount, maxCount int) (Regexp, error) {
+	if minCount < 0 || (maxCount >= 0 && maxCount < minCount) {
+		return nil, ErrBadClosure
+	}
+	if minCount > maxRepeatCount || maxCount > maxRepeatCount {
+		return nil, ErrBadClosure
+	}
+
+	if minCount == 0 && maxCount == 0 {
+		return regexpEmpty{}, nil
+	}
+
+	var regexp Regexp = regexpEmpty{}
+	for i := 0; i < minCount; i++ {
+		regexp = appendRegexp(regexp, child)
+	}
+
+	if maxCount < 0 {
+		// {n,}: n required copies followed by a star for the rest.
+		return appendRegexp(regexp, regexpStar{child: child}), nil
+	}
+
+	// {n,m}: n required copies followed by (m - n) optional copies.
+	for i := minCount; i < maxCount; i++ {
+		regexp = appendRegexp(regexp, regexpUnion{left: regexpEmpty{}, right: child})
+	}
+
+	return regexp, nil
 }
 
 func parseCharacterClass(s string, pos int) (Regexp, int, error) {
@@ -282,25 +476,63 @@ func parseCharacterClass(s string, pos int) (Regexp, int, error) {
 		pos++
 	}
 
-	// Consume all characters up to and including the closing ']'
 	for pos < len(s) {
 		if s[pos] == ']' {
 			pos++
 			return regexp, pos, nil
-		} else if s[pos] == '\\' {
-			if pos+1 >= len(s) {
-				return nil, 0, errors.New("Invalid escape sequence in character class")
-			} else if c := s[pos+1]; c == '[' || c == ']' || c == '^' || c == '\\' {
-				regexp.chars = append(regexp.chars, c)
-				pos += 2
-			} else {
-				return nil, 0, errors.New("Unrecognized escape sequence in character class")
+		}
+
+		c, newPos, err := parseCharClassAtom(s, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		pos = newPos
+
+		// Check for a range a-z: the atom is followed by '-' and another atom
+		// that isn't the closing bracket.
+		if pos < len(s) && s[pos] == '-' && pos+1 < len(s) && s[pos+1] != ']' {
+			hi, newPos, err := parseCharClassAtom(s, pos+1)
+			if err != nil {
+				return nil, 0, err
 			}
-		} else {
-			regexp.chars = append(regexp.chars, s[pos])
-			pos++
+			if hi < c {
+				return nil, 0, ErrBadRange
+			}
+			if int64(hi)-int64(c)+1 > maxCharClassRangeSize {
+				return nil, 0, ErrBadRange
+			}
+			regexp.ranges = append(regexp.ranges, runeRange{lo: c, hi: hi})
+			pos = newPos
+			continue
 		}
+
+		regexp.chars = append(regexp.chars, c)
 	}
 
 	return nil, 0, errors.New("Expected closing bracket")
 }
+
+// parseCharClassAtom parses a single character or escape sequence inside a
+// character class, returning the rune it represents and the position
+// immediately after it.
+func parseCharClassAtom(s string, pos int) (r rune, newPos int, err error) {
+	if s[pos] != '\\' {
+		r, width := utf8.DecodeRuneInString(s[pos:])
+		return r, pos + width, nil
+	}
+
+	if pos+1 >= len(s) {
+		return 0, 0, ErrTrailingBackslash
+	}
+
+	c, width := utf8.DecodeRuneInString(s[pos+1:])
+	switch c {
+	case '[', ']', '^', '\\', '-':
+		return c, pos + 1 + width, nil
+	default:
+		if escaped, ok := simpleCharEscapes[c]; ok {
+			return escaped, pos + 1 + width, nil
+		}
+		return 0, 0, errors.New("Unrecognized escape sequence in character class")
+	}
+}
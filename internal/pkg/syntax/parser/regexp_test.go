@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRegexpLiteralBrace(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected Regexp
+	}{
+		{
+			name:     "lone open brace matches itself",
+			input:    "{",
+			expected: regexpChar{char: '{'},
+		},
+		{
+			name:     "lone close brace matches itself",
+			input:    "}",
+			expected: regexpChar{char: '}'},
+		},
+		{
+			name:  "brace pair that isn't a valid repetition count matches literally",
+			input: "a{x}",
+			expected: regexpConcat{
+				left: regexpConcat{
+					left: regexpConcat{
+						left:  regexpChar{char: 'a'},
+						right: regexpChar{char: '{'},
+					},
+					right: regexpChar{char: 'x'},
+				},
+				right: regexpChar{char: '}'},
+			},
+		},
+		{
+			name:     "valid bounded repetition is still desugared, not matched literally",
+			input:    "a{2}",
+			expected: regexpConcat{left: regexpChar{char: 'a'}, right: regexpChar{char: 'a'}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseRegexp(tc.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+// TestParseRegexpBoundedRepetitionTooLarge covers a bounded repetition whose
+// count is unreasonably large (e.g. a user-supplied search pattern like
+// `x{100000000}`), which must be rejected rather than desugared into a
+// concatenation with a node per repetition.
+func TestParseRegexpBoundedRepetitionTooLarge(t *testing.T) {
+	_, err := ParseRegexp("a{100000000}")
+	assert.ErrorIs(t, err, ErrBadClosure)
+}
+
+// TestParseCharacterClassRejectsOversizedRange covers a character class range
+// spanning the entire Unicode codepoint space (U+0000 to U+10FFFF);
+// since this parser is rune-based rather than byte-based, expanding it in
+// full would put over a million runes in memory, so parsing must reject it
+// with ErrBadRange rather than silently truncating the matched set.
+func TestParseCharacterClassRejectsOversizedRange(t *testing.T) {
+	_, err := ParseRegexp("[\x00-\U0010FFFF]")
+	assert.ErrorIs(t, err, ErrBadRange)
+}
+
+// TestExpandCharClassExpandsRangeInFull covers a range within bounds:
+// expandCharClass itself no longer caps anything (parseCharacterClass rejects
+// oversized ranges earlier), so it must expand the full range it's given.
+func TestExpandCharClassExpandsRangeInFull(t *testing.T) {
+	result := expandCharClass(nil, []runeRange{{lo: 'a', hi: 'e'}})
+	assert.Equal(t, []rune("abcde"), result)
+}
@@ -0,0 +1,41 @@
+package state
+
+import "sort"
+
+// MacroRegisters tracks, for the lifetime of an editor session, which
+// registers currently hold a recorded macro and a human-readable summary of
+// each one. It is a standalone type rather than a field on EditorState so it
+// can be introduced without touching every call site that constructs one;
+// input.Interpreter is the only thing that replays macros, so this only
+// needs to hold enough for a `:reg` command to list them.
+type MacroRegisters struct {
+	summaries map[rune]string
+}
+
+// NewMacroRegisters creates an empty set of macro registers.
+func NewMacroRegisters() *MacroRegisters {
+	return &MacroRegisters{summaries: make(map[rune]string)}
+}
+
+// SetMacro records summary as the description of the macro in register,
+// replacing whatever was previously recorded there.
+func (r *MacroRegisters) SetMacro(register rune, summary string) {
+	r.summaries[register] = summary
+}
+
+// Macro returns the summary recorded into register, if any.
+func (r *MacroRegisters) Macro(register rune) (string, bool) {
+	summary, ok := r.summaries[register]
+	return summary, ok
+}
+
+// RegisterNames returns, in ascending order, every register that currently
+// holds a recorded macro. A `:reg` command uses this to list them.
+func (r *MacroRegisters) RegisterNames() []rune {
+	names := make([]rune, 0, len(r.summaries))
+	for reg := range r.summaries {
+		names = append(names, reg)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
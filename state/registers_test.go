@@ -0,0 +1,28 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMacroRegisters(t *testing.T) {
+	r := NewMacroRegisters()
+
+	_, ok := r.Macro('a')
+	assert.False(t, ok)
+	assert.Empty(t, r.RegisterNames())
+
+	r.SetMacro('b', "ddp")
+	r.SetMacro('a', "ihello<Esc>")
+	assert.Equal(t, []rune{'a', 'b'}, r.RegisterNames())
+
+	summary, ok := r.Macro('a')
+	assert.True(t, ok)
+	assert.Equal(t, "ihello<Esc>", summary)
+
+	r.SetMacro('a', "x")
+	summary, ok = r.Macro('a')
+	assert.True(t, ok)
+	assert.Equal(t, "x", summary)
+}
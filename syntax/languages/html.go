@@ -0,0 +1,187 @@
+package languages
+
+import (
+	"unicode"
+
+	"github.com/aretext/aretext/syntax/parser"
+)
+
+const (
+	htmlTokenRoleTag             = parser.TokenRoleCustom1
+	htmlTokenRoleAttrKey         = parser.TokenRoleCustom2
+	htmlTokenRoleCharacterEntity = parser.TokenRoleCustom3
+	htmlTokenRoleDoctype         = parser.TokenRoleCustom4
+)
+
+// htmlMode tracks which WHATWG-ish tokenizer state the parser is in.
+// Script and style elements get their own "in tag" and "raw text" modes so
+// that, once the opening tag closes, the parser stays in raw text until it
+// sees the matching closing tag rather than reinterpreting markup inside.
+type htmlMode uint8
+
+const (
+	htmlModeData = htmlMode(iota)
+	htmlModeInTag
+	htmlModeInScriptTag
+	htmlModeInStyleTag
+	htmlModeInScriptData
+	htmlModeInStyleData
+)
+
+type htmlParseState struct {
+	mode htmlMode
+}
+
+func (s htmlParseState) Equals(other parser.State) bool {
+	otherState, ok := other.(htmlParseState)
+	return ok && s == otherState
+}
+
+func dataState() htmlParseState       { return htmlParseState{mode: htmlModeData} }
+func inTagState() htmlParseState      { return htmlParseState{mode: htmlModeInTag} }
+func scriptTagState() htmlParseState  { return htmlParseState{mode: htmlModeInScriptTag} }
+func styleTagState() htmlParseState   { return htmlParseState{mode: htmlModeInStyleTag} }
+func scriptDataState() htmlParseState { return htmlParseState{mode: htmlModeInScriptData} }
+func styleDataState() htmlParseState  { return htmlParseState{mode: htmlModeInStyleData} }
+
+func isTagNameRune(r rune) bool {
+	return r != '>' && r != '/' && !unicode.IsSpace(r)
+}
+
+// HtmlParseFunc returns a parse func for HTML5, modeled on the WHATWG
+// tokenizer states (https://html.spec.whatwg.org/multipage/parsing.html#tokenization).
+// Unlike XmlParseFunc, it tolerates unquoted attribute values, void elements,
+// and the raw-text content of <script>/<style> elements.
+func HtmlParseFunc() parser.Func {
+	parseComment := matchState(
+		dataState(),
+		consumeString("<!--").
+			Then(consumeToString("-->")).
+			Map(recognizeToken(parser.TokenRoleComment)))
+
+	parseDoctype := matchState(
+		dataState(),
+		consumeStringCaseInsensitive("<!doctype").
+			Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '>' })).
+			ThenMaybe(consumeString(">")).
+			Map(recognizeToken(htmlTokenRoleDoctype)))
+
+	parseCharacterEntity := matchState(
+		dataState(),
+		consumeString("&").
+			Then(consumeRunesLike(func(r rune) bool { return r != '<' && r != '>' && r != ';' && !unicode.IsSpace(r) })).
+			Then(consumeString(";")).
+			Map(recognizeToken(htmlTokenRoleCharacterEntity)))
+
+	parseScriptTagStart := matchState(
+		dataState(),
+		consumeStringCaseInsensitive("<script").
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(scriptTagState())))
+
+	parseStyleTagStart := matchState(
+		dataState(),
+		consumeStringCaseInsensitive("<style").
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(styleTagState())))
+
+	parseTagStart := matchState(
+		dataState(),
+		consumeLongestMatchingOption([]string{"<", "</"}).
+			ThenMaybe(consumeRunesLike(isTagNameRune)).
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(inTagState())))
+
+	parseAttrKey := consumeRunesLike(func(r rune) bool { return r != '>' && r != '=' && !unicode.IsSpace(r) }).
+		Then(consumeString("=")).
+		Map(recognizeToken(htmlTokenRoleAttrKey))
+
+	consumeAttrValSingleQuote := consumeString("'").
+		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '\'' || r == '>' }))
+
+	consumeAttrValDoubleQuote := consumeString("\"").
+		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '"' || r == '>' }))
+
+	consumeAttrValUnquoted := consumeRunesLike(func(r rune) bool { return r != '>' && !unicode.IsSpace(r) })
+
+	parseAttrVal := consumeAttrValSingleQuote.
+		Or(consumeAttrValDoubleQuote).
+		Or(consumeAttrValUnquoted).
+		Map(recognizeToken(parser.TokenRoleString))
+
+	parseTagContent := matchState(inTagState(), parseAttrVal.Or(parseAttrKey))
+	parseScriptTagContent := matchState(scriptTagState(), parseAttrVal.Or(parseAttrKey))
+	parseStyleTagContent := matchState(styleTagState(), parseAttrVal.Or(parseAttrKey))
+
+	parseTagEnd := matchState(
+		inTagState(),
+		consumeLongestMatchingOption([]string{">", "/>"}).
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(dataState())))
+
+	parseScriptTagEnd := matchState(
+		scriptTagState(),
+		consumeLongestMatchingOption([]string{">", "/>"}).
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(scriptDataState())))
+
+	parseStyleTagEnd := matchState(
+		styleTagState(),
+		consumeLongestMatchingOption([]string{">", "/>"}).
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(styleDataState())))
+
+	// Raw text content: script/style markup is not reinterpreted as tags until
+	// the matching closing tag, but embedded strings and comments are still
+	// recognized so the element's content highlights sensibly.
+	parseJsLineComment := consumeString("//").
+		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '\n' })).
+		Map(recognizeToken(parser.TokenRoleComment))
+
+	parseBlockComment := consumeString("/*").
+		Then(consumeToString("*/")).
+		Map(recognizeToken(parser.TokenRoleComment))
+
+	parseRawTextString := consumeLongestMatchingOption([]string{"'", "\"", "`"}).
+		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '\n' })).
+		Map(recognizeToken(parser.TokenRoleString))
+
+	parseScriptClose := matchState(
+		scriptDataState(),
+		consumeStringCaseInsensitive("</script").
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(inTagState())))
+
+	parseStyleClose := matchState(
+		styleDataState(),
+		consumeStringCaseInsensitive("</style").
+			Map(recognizeToken(htmlTokenRoleTag)).
+			Map(setState(inTagState())))
+
+	parseScriptData := matchState(
+		scriptDataState(),
+		parseJsLineComment.Or(parseBlockComment).Or(parseRawTextString))
+
+	parseStyleData := matchState(
+		styleDataState(),
+		parseBlockComment.Or(parseRawTextString))
+
+	return initialState(
+		dataState(),
+		parseComment.
+			Or(parseDoctype).
+			Or(parseCharacterEntity).
+			Or(parseScriptTagStart).
+			Or(parseStyleTagStart).
+			Or(parseTagStart).
+			Or(parseTagContent).
+			Or(parseScriptTagContent).
+			Or(parseStyleTagContent).
+			Or(parseTagEnd).
+			Or(parseScriptTagEnd).
+			Or(parseStyleTagEnd).
+			Or(parseScriptClose).
+			Or(parseStyleClose).
+			Or(parseScriptData).
+			Or(parseStyleData))
+}
@@ -0,0 +1,65 @@
+package languages
+
+import (
+	"testing"
+
+	"github.com/aretext/aretext/syntax/parser"
+	"github.com/aretext/aretext/text"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTagNameRune(t *testing.T) {
+	testCases := []struct {
+		name     string
+		r        rune
+		expected bool
+	}{
+		{name: "letter", r: 'a', expected: true},
+		{name: "digit", r: '1', expected: true},
+		{name: "greater than ends tag name", r: '>', expected: false},
+		{name: "slash ends tag name", r: '/', expected: false},
+		{name: "space ends tag name", r: ' ', expected: false},
+		{name: "newline ends tag name", r: '\n', expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isTagNameRune(tc.r))
+		})
+	}
+}
+
+// TestHtmlParseStateEquals covers htmlParseState.Equals, which the underlying
+// parser.Func combinators rely on to detect mode transitions (e.g. entering
+// script/style raw text) between retokenization passes.
+func TestHtmlParseStateEquals(t *testing.T) {
+	assert.True(t, dataState().Equals(dataState()))
+	assert.False(t, dataState().Equals(scriptDataState()))
+	assert.False(t, scriptTagState().Equals(styleTagState()))
+
+	// A value of an unrelated type is never equal, regardless of its contents.
+	assert.False(t, dataState().Equals(xmlParseStateNormal))
+}
+
+// TestHtmlParseFuncTokenizesScriptRawText covers HtmlParseFunc end to end on
+// a <script> element: once the opening tag closes, the parser must switch
+// into raw-text mode and recognize a "//" line comment inside it (rather than
+// reinterpreting the tag-like "</script" as markup until the matching closing
+// tag is actually reached), then switch back to tag mode for that closing
+// tag.
+func TestHtmlParseFuncTokenizesScriptRawText(t *testing.T) {
+	const src = "<script>// x\n</script>"
+	tree, err := text.NewTreeFromString(src)
+	assert.NoError(t, err)
+
+	tokens, err := HtmlParseFunc().Parse(tree)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []parser.Token{
+		{StartPos: 0, EndPos: 7, Role: htmlTokenRoleTag},         // "<script"
+		{StartPos: 7, EndPos: 8, Role: htmlTokenRoleTag},         // ">"
+		{StartPos: 8, EndPos: 12, Role: parser.TokenRoleComment}, // "// x"
+		{StartPos: 13, EndPos: 21, Role: htmlTokenRoleTag},       // "</script"
+		{StartPos: 21, EndPos: 22, Role: htmlTokenRoleTag},       // ">"
+	}, tokens)
+}
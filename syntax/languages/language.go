@@ -0,0 +1,41 @@
+package languages
+
+import "github.com/aretext/aretext/syntax/parser"
+
+// Language identifies a markup or programming language that aretext can
+// syntax-highlight.
+type Language string
+
+const (
+	LanguageXml  = Language("xml")
+	LanguageHtml = Language("html")
+)
+
+// languageByExtension maps a file extension (including the leading dot) to
+// the language used to syntax-highlight it.
+var languageByExtension = map[string]Language{
+	".xml":  LanguageXml,
+	".html": LanguageHtml,
+	".htm":  LanguageHtml,
+}
+
+// LanguageFromFileExtension looks up the language registered for ext
+// (including the leading dot). The second return value is false if no
+// language is registered for that extension.
+func LanguageFromFileExtension(ext string) (Language, bool) {
+	lang, ok := languageByExtension[ext]
+	return lang, ok
+}
+
+// ParseFuncForLanguage returns the parser.Func used to tokenize lang, or nil
+// if lang isn't recognized.
+func ParseFuncForLanguage(lang Language) parser.Func {
+	switch lang {
+	case LanguageXml:
+		return XmlParseFunc()
+	case LanguageHtml:
+		return HtmlParseFunc()
+	default:
+		return nil
+	}
+}
@@ -1,17 +1,28 @@
 package languages
 
 import (
+	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/aretext/aretext/syntax/parser"
 )
 
 const (
-	xmlTokenRoleAttrKey         = parser.TokenRoleCustom1
-	xmlTokenRoleCharacterEntity = parser.TokenRoleCustom2
-	xmlTokenRoleCData           = parser.TokenRoleCustom3
-	xmlTokenRoleTag             = parser.TokenRoleCustom4
-	xmlTokenRolePrologue        = parser.TokenRoleCustom5
+	XmlTokenRoleAttrKey         = parser.TokenRoleCustom1
+	XmlTokenRoleCharacterEntity = parser.TokenRoleCustom2
+	XmlTokenRoleCData           = parser.TokenRoleCustom3
+	XmlTokenRoleTag             = parser.TokenRoleCustom4
+	XmlTokenRolePrologue        = parser.TokenRoleCustom5
+	XmlTokenRoleDoctype         = parser.TokenRoleCustom6
+	XmlTokenRoleEntityDecl      = parser.TokenRoleCustom7
+	// XmlTokenRoleEntityRef marks one of the five predefined entity
+	// references (&amp; &lt; &gt; &quot; &apos;), distinct from numeric
+	// character references and other named entity references.
+	XmlTokenRoleEntityRef = parser.TokenRoleCustom8
+	// XmlTokenRoleEntityError marks a malformed or unterminated character or
+	// entity reference (e.g. an invalid numeric codepoint, or a missing ";").
+	XmlTokenRoleEntityError = parser.TokenRoleCustom9
 )
 
 type xmlParseState uint8
@@ -19,6 +30,22 @@ type xmlParseState uint8
 const (
 	xmlParseStateNormal = xmlParseState(iota)
 	xmlParseStateInTag
+	// xmlParseStateInDoctype covers "<!DOCTYPE" up to the internal subset
+	// (if any) or the closing ">".
+	xmlParseStateInDoctype
+	// xmlParseStateInInternalSubset covers the bracketed "[...]" portion of a
+	// DOCTYPE declaration, between markup declarations.
+	xmlParseStateInInternalSubset
+	// xmlParseStateInDoctypeDecl covers the body of a single <!ELEMENT>,
+	// <!ATTLIST>, <!ENTITY>, or <!NOTATION> declaration inside the internal
+	// subset, up to its closing ">". Quoted literals are tracked explicitly
+	// so a ">" inside one doesn't end the declaration early.
+	xmlParseStateInDoctypeDecl
+	// xmlParseStateInAttrValueSingle and xmlParseStateInAttrValueDouble cover
+	// the body of a '...'- or "..."-quoted attribute value, broken into
+	// plain-text chunks interspersed with character/entity reference tokens.
+	xmlParseStateInAttrValueSingle
+	xmlParseStateInAttrValueDouble
 )
 
 func (s xmlParseState) Equals(other parser.State) bool {
@@ -33,13 +60,13 @@ func XmlParseFunc() parser.Func {
 		xmlParseStateNormal,
 		consumeString("<?").
 			Then(consumeToString("?>")).
-			Map(recognizeToken(xmlTokenRolePrologue)))
+			Map(recognizeToken(XmlTokenRolePrologue)))
 
 	parseCData := matchState(
 		xmlParseStateNormal,
 		consumeString("<![CDATA[").
 			Then(consumeToString("]]>")).
-			Map(recognizeToken(xmlTokenRoleCData)))
+			Map(recognizeToken(XmlTokenRoleCData)))
 
 	parseComment := matchState(
 		xmlParseStateNormal,
@@ -51,49 +78,268 @@ func XmlParseFunc() parser.Func {
 		xmlParseStateNormal,
 		consumeLongestMatchingOption([]string{"<", "</"}).
 			ThenMaybe(consumeRunesLike(func(r rune) bool { return r != '>' && r != '/' && !unicode.IsSpace(r) })).
-			Map(recognizeToken(xmlTokenRoleTag)).
+			Map(recognizeToken(XmlTokenRoleTag)).
 			Map(setState(xmlParseStateInTag)))
 
-	parseCharacterEntity := matchState(
-		xmlParseStateNormal,
-		consumeString("&").
-			Then(consumeRunesLike(func(r rune) bool { return r != '<' && r != '>' && r != ';' && !unicode.IsSpace(r) })).
-			Then(consumeString(";")).
-			Map(recognizeToken(xmlTokenRoleCharacterEntity)))
+	// parseEntityRef matches a character reference ("&#38;", "&#x26;") or
+	// entity reference ("&amp;", "&foo;"), classifying it by the content
+	// between "&" and ";" rather than assigning a single fixed role: the five
+	// predefined entities get their own role, valid numeric and generic named
+	// references reuse XmlTokenRoleCharacterEntity, and anything malformed or
+	// missing its terminating ";" is flagged with XmlTokenRoleEntityError.
+	parseEntityRef := consumeString("&").
+		Then(consumeRunesLike(func(r rune) bool { return r != '<' && r != '>' && r != ';' && !unicode.IsSpace(r) })).
+		ThenMaybe(consumeString(";")).
+		Map(recognizeTokenFunc(classifyEntityRef))
+
+	parseCharacterEntity := matchState(xmlParseStateNormal, parseEntityRef)
 
 	parseAttrKey := consumeRunesLike(func(r rune) bool { return r != '>' && r != '=' && !unicode.IsSpace(r) }).
 		Then(consumeString("=")).
-		Map(recognizeToken(xmlTokenRoleAttrKey))
+		Map(recognizeToken(XmlTokenRoleAttrKey))
+
+	parseAttrValSingleStart := consumeString("'").
+		Map(recognizeToken(parser.TokenRoleString)).
+		Map(setState(xmlParseStateInAttrValueSingle))
 
-	consumeAttrValSingleQuote := consumeString("'").
-		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '\'' || r == '\n' || r == '>' }))
+	parseAttrValDoubleStart := consumeString("\"").
+		Map(recognizeToken(parser.TokenRoleString)).
+		Map(setState(xmlParseStateInAttrValueDouble))
 
-	consumeAttrValDoubleQuote := consumeString("\"").
-		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '"' || r == '\n' || r == '>' }))
+	parseAttrValSingleText := matchState(
+		xmlParseStateInAttrValueSingle,
+		consumeRunesLike(func(r rune) bool { return r != '\'' && r != '\n' && r != '>' && r != '&' }).
+			Map(recognizeToken(parser.TokenRoleString)))
 
-	parseAttrVal := consumeAttrValSingleQuote.
-		Or(consumeAttrValDoubleQuote).
-		Map(recognizeToken(parser.TokenRoleString))
+	parseAttrValDoubleText := matchState(
+		xmlParseStateInAttrValueDouble,
+		consumeRunesLike(func(r rune) bool { return r != '"' && r != '\n' && r != '>' && r != '&' }).
+			Map(recognizeToken(parser.TokenRoleString)))
+
+	parseAttrValSingleEntity := matchState(xmlParseStateInAttrValueSingle, parseEntityRef)
+	parseAttrValDoubleEntity := matchState(xmlParseStateInAttrValueDouble, parseEntityRef)
+
+	parseAttrValSingleEnd := matchState(
+		xmlParseStateInAttrValueSingle,
+		consumeString("'").
+			Map(recognizeToken(parser.TokenRoleString)).
+			Map(setState(xmlParseStateInTag)))
+
+	parseAttrValDoubleEnd := matchState(
+		xmlParseStateInAttrValueDouble,
+		consumeString("\"").
+			Map(recognizeToken(parser.TokenRoleString)).
+			Map(setState(xmlParseStateInTag)))
 
 	parseTagContent := matchState(
 		xmlParseStateInTag,
-		parseAttrVal.Or(parseAttrKey))
+		parseAttrValSingleStart.
+			Or(parseAttrValDoubleStart).
+			Or(parseAttrKey))
+
+	parseAttrValBody := parseAttrValSingleText.
+		Or(parseAttrValDoubleText).
+		Or(parseAttrValSingleEntity).
+		Or(parseAttrValDoubleEntity).
+		Or(parseAttrValSingleEnd).
+		Or(parseAttrValDoubleEnd)
 
 	parseTagEnd := matchState(
 		xmlParseStateInTag,
 		consumeLongestMatchingOption([]string{">", "/>"}).
-			Map(recognizeToken(xmlTokenRoleTag)).
+			Map(recognizeToken(XmlTokenRoleTag)).
 			Map(setState(xmlParseStateNormal)))
 
 	parseTag := parseTagStart.
 		Or(parseTagContent).
 		Or(parseTagEnd)
 
+	consumeQuotedLiteral := consumeString("'").
+		Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '\'' })).
+		Or(consumeString("\"").
+			Then(consumeToEofOrRuneLike(func(r rune) bool { return r == '"' })))
+
+	peReference := consumeString("%").
+		Then(consumeRunesLike(func(r rune) bool { return r != ';' && !unicode.IsSpace(r) })).
+		Then(consumeString(";")).
+		Map(recognizeToken(XmlTokenRoleCharacterEntity))
+
+	parseDoctypeStart := matchState(
+		xmlParseStateNormal,
+		consumeString("<!DOCTYPE").
+			Map(recognizeToken(XmlTokenRoleDoctype)).
+			Map(setState(xmlParseStateInDoctype)))
+
+	parseDoctypeKeyword := matchState(
+		xmlParseStateInDoctype,
+		consumeLongestMatchingOption([]string{"SYSTEM", "PUBLIC"}).
+			Map(recognizeToken(XmlTokenRoleDoctype)))
+
+	parseDoctypeLiteral := matchState(
+		xmlParseStateInDoctype,
+		consumeQuotedLiteral.Map(recognizeToken(parser.TokenRoleString)))
+
+	parseDoctypeSubsetStart := matchState(
+		xmlParseStateInDoctype,
+		consumeString("[").
+			Map(recognizeToken(XmlTokenRoleTag)).
+			Map(setState(xmlParseStateInInternalSubset)))
+
+	parseDoctypeEnd := matchState(
+		xmlParseStateInDoctype,
+		consumeString(">").
+			Map(recognizeToken(XmlTokenRoleDoctype)).
+			Map(setState(xmlParseStateNormal)))
+
+	parseSubsetComment := matchState(
+		xmlParseStateInInternalSubset,
+		consumeString("<!--").
+			Then(consumeToString("-->")).
+			Map(recognizeToken(parser.TokenRoleComment)))
+
+	parseSubsetPEReference := matchState(xmlParseStateInInternalSubset, peReference)
+
+	parseEntityDeclStart := matchState(
+		xmlParseStateInInternalSubset,
+		consumeString("<!ENTITY").
+			Map(recognizeToken(XmlTokenRoleEntityDecl)).
+			Map(setState(xmlParseStateInDoctypeDecl)))
+
+	parseOtherDeclStart := matchState(
+		xmlParseStateInInternalSubset,
+		consumeLongestMatchingOption([]string{"<!ELEMENT", "<!ATTLIST", "<!NOTATION"}).
+			Map(recognizeToken(XmlTokenRoleTag)).
+			Map(setState(xmlParseStateInDoctypeDecl)))
+
+	parseSubsetEnd := matchState(
+		xmlParseStateInInternalSubset,
+		consumeString("]").
+			Map(recognizeToken(XmlTokenRoleTag)).
+			Map(setState(xmlParseStateInDoctype)))
+
+	parseDeclQuotedLiteral := matchState(
+		xmlParseStateInDoctypeDecl,
+		consumeQuotedLiteral.Map(recognizeToken(parser.TokenRoleString)))
+
+	parseDeclPEReference := matchState(xmlParseStateInDoctypeDecl, peReference)
+
+	parseDeclEnd := matchState(
+		xmlParseStateInDoctypeDecl,
+		consumeString(">").
+			Map(recognizeToken(XmlTokenRoleTag)).
+			Map(setState(xmlParseStateInInternalSubset)))
+
+	parseDoctype := parseDoctypeStart.
+		Or(parseDoctypeKeyword).
+		Or(parseDoctypeLiteral).
+		Or(parseDoctypeSubsetStart).
+		Or(parseDoctypeEnd).
+		Or(parseSubsetComment).
+		Or(parseSubsetPEReference).
+		Or(parseEntityDeclStart).
+		Or(parseOtherDeclStart).
+		Or(parseSubsetEnd).
+		Or(parseDeclQuotedLiteral).
+		Or(parseDeclPEReference).
+		Or(parseDeclEnd)
+
 	return initialState(
 		xmlParseStateNormal,
 		parseComment.
 			Or(parsePrologue).
 			Or(parseCData).
 			Or(parseCharacterEntity).
-			Or(parseTag))
+			Or(parseDoctype).
+			Or(parseTag).
+			Or(parseAttrValBody))
+}
+
+// classifyEntityRef assigns a token role to a matched "&...;" reference based
+// on its content, rather than treating every reference identically.
+func classifyEntityRef(matchedText string) parser.TokenRole {
+	if !strings.HasSuffix(matchedText, ";") {
+		return XmlTokenRoleEntityError
+	}
+
+	inner := strings.TrimSuffix(strings.TrimPrefix(matchedText, "&"), ";")
+
+	switch {
+	case isPredefinedEntityName(inner):
+		return XmlTokenRoleEntityRef
+	case strings.HasPrefix(inner, "#x") || strings.HasPrefix(inner, "#X"):
+		if isLegalXmlCodepoint(inner[2:], 16) {
+			return XmlTokenRoleCharacterEntity
+		}
+		return XmlTokenRoleEntityError
+	case strings.HasPrefix(inner, "#"):
+		if isLegalXmlCodepoint(inner[1:], 10) {
+			return XmlTokenRoleCharacterEntity
+		}
+		return XmlTokenRoleEntityError
+	case isXmlName(inner):
+		return XmlTokenRoleCharacterEntity
+	default:
+		return XmlTokenRoleEntityError
+	}
+}
+
+func isPredefinedEntityName(name string) bool {
+	switch name {
+	case "amp", "lt", "gt", "quot", "apos":
+		return true
+	default:
+		return false
+	}
+}
+
+// isLegalXmlCodepoint reports whether digits (base 10 or 16) parse to a
+// codepoint that XML 1.0/1.1 allow as a literal character.
+// See https://www.w3.org/TR/2006/REC-xml11-20060816/#charsets
+func isLegalXmlCodepoint(digits string, base int) bool {
+	if digits == "" {
+		return false
+	}
+	n, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		return false
+	}
+	r := rune(n)
+	switch {
+	case r == 0x9 || r == 0xA || r == 0xD:
+		return true
+	case r >= 0x20 && r <= 0xD7FF:
+		return true
+	case r >= 0xE000 && r <= 0xFFFD:
+		return true
+	case r >= 0x10000 && r <= 0x10FFFF:
+		return true
+	default:
+		return false
+	}
+}
+
+// isXmlName reports whether s matches the XML Name production (simplified to
+// Unicode letters rather than the full NameStartChar/NameChar tables).
+func isXmlName(s string) bool {
+	if s == "" {
+		return false
+	}
+	runes := []rune(s)
+	if !isXmlNameStartRune(runes[0]) {
+		return false
+	}
+	for _, r := range runes[1:] {
+		if !isXmlNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isXmlNameStartRune(r rune) bool {
+	return r == ':' || r == '_' || unicode.IsLetter(r)
+}
+
+func isXmlNameRune(r rune) bool {
+	return isXmlNameStartRune(r) || r == '-' || r == '.' || unicode.IsDigit(r)
 }
@@ -0,0 +1,36 @@
+package languages
+
+import (
+	"testing"
+
+	"github.com/aretext/aretext/syntax/parser"
+	"github.com/aretext/aretext/text"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestXmlParseFuncTokenizesTagAndEntityRef covers XmlParseFunc end to end on
+// a tag with a quoted attribute value and a predefined entity reference in
+// its character data, rather than only exercising the unexported helpers it's
+// built from: the quoted value must split into open-quote/body/close-quote
+// tokens (not one token for the whole value), and "&amp;" must be classified
+// as XmlTokenRoleEntityRef rather than the generic character-entity role.
+func TestXmlParseFuncTokenizesTagAndEntityRef(t *testing.T) {
+	const src = `<a href="x">cats &amp; dogs</a>`
+	tree, err := text.NewTreeFromString(src)
+	assert.NoError(t, err)
+
+	tokens, err := XmlParseFunc().Parse(tree)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []parser.Token{
+		{StartPos: 0, EndPos: 2, Role: XmlTokenRoleTag},          // "<a"
+		{StartPos: 3, EndPos: 8, Role: XmlTokenRoleAttrKey},      // "href="
+		{StartPos: 8, EndPos: 9, Role: parser.TokenRoleString},   // `"`
+		{StartPos: 9, EndPos: 10, Role: parser.TokenRoleString},  // "x"
+		{StartPos: 10, EndPos: 11, Role: parser.TokenRoleString}, // `"`
+		{StartPos: 11, EndPos: 12, Role: XmlTokenRoleTag},        // ">"
+		{StartPos: 17, EndPos: 22, Role: XmlTokenRoleEntityRef},  // "&amp;"
+		{StartPos: 27, EndPos: 30, Role: XmlTokenRoleTag},        // "</a"
+		{StartPos: 30, EndPos: 31, Role: XmlTokenRoleTag},        // ">"
+	}, tokens)
+}
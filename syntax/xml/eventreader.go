@@ -0,0 +1,412 @@
+// Package xml provides a structured, pull-based view of an XML document on
+// top of languages.XmlParseFunc's token stream, for features (outline
+// navigation, tag-balance motions, matching-tag jumps) that need more
+// structure than syntax coloring alone.
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aretext/aretext/syntax/languages"
+	"github.com/aretext/aretext/syntax/parser"
+	"github.com/aretext/aretext/text"
+)
+
+// xmlNamespaceURI and xmlnsNamespaceURI are the two namespace URIs XML itself
+// predefines, bound to the "xml" and "xmlns" prefixes regardless of any
+// xmlns:xml or xmlns:xmlns declaration.
+const (
+	xmlNamespaceURI   = "http://www.w3.org/XML/1998/namespace"
+	xmlnsNamespaceURI = "http://www.w3.org/2000/xmlns/"
+)
+
+// ErrMismatchedEndTag is returned when an end tag doesn't match the name of
+// the element it closes.
+var ErrMismatchedEndTag = errors.New("xml: mismatched end tag")
+
+// ErrUnclosedElement is returned by Next when the token stream ends with one
+// or more elements still open.
+var ErrUnclosedElement = errors.New("xml: unclosed element")
+
+// EventType identifies the kind of structural event an EventReader emits.
+type EventType uint8
+
+const (
+	EventStartElement = EventType(iota)
+	EventEndElement
+	EventCharData
+	EventCData
+	EventComment
+	EventProcessingInstruction
+	EventPrologue
+)
+
+// Name is a namespace-resolved element or attribute name.
+type Name struct {
+	Local string
+	URI   string
+}
+
+// Attr is a single resolved attribute of a StartElement event.
+type Attr struct {
+	Name  Name
+	Value string
+}
+
+// Event is a single structural event in the document, built from one or more
+// tokens. Pos is the position in the underlying text tree where the event
+// begins, counted in characters (runes) like token positions, not in bytes.
+type Event struct {
+	Type  EventType
+	Pos   uint64
+	Name  Name   // set for EventStartElement and EventEndElement
+	Attrs []Attr // set for EventStartElement
+	Data  string // set for EventCharData, EventCData, EventComment, EventProcessingInstruction, EventPrologue
+}
+
+// nsFrame is the set of prefix -> URI bindings in scope for one open element,
+// including the default (unprefixed) namespace under the empty prefix "".
+type nsFrame struct {
+	bindings map[string]string
+}
+
+func (f nsFrame) resolve(prefix string) (string, bool) {
+	uri, ok := f.bindings[prefix]
+	return uri, ok
+}
+
+// rawAttr is an attribute key/value pair as read off the token stream,
+// before namespace resolution.
+type rawAttr struct {
+	key string
+	val string
+}
+
+// openElement tracks an element on the stack between its StartElement and
+// EndElement events, so the end tag can be validated and resolved using the
+// namespace bindings that were in effect when it was opened.
+type openElement struct {
+	rawName string
+	name    Name
+	frame   nsFrame
+	pos     uint64
+}
+
+// EventReader pulls structural Events out of a token stream already produced
+// by languages.XmlParseFunc (or an equivalent tokenizer). Call Next
+// repeatedly until it returns io.EOF.
+type EventReader struct {
+	tree   *text.Tree
+	tokens []parser.Token
+
+	pos         int
+	consumedPos uint64
+
+	inDoctype   bool
+	sawPrologue bool
+
+	pending []Event
+	nsStack []nsFrame
+	elems   []openElement
+}
+
+// NewEventReader builds an EventReader over tokens produced by tokenizing
+// tree with languages.XmlParseFunc. It reads XmlParseFunc's token roles;
+// languages.HtmlParseFunc assigns different roles to its tags and attributes,
+// so its tokens are not read as tags here.
+func NewEventReader(tree *text.Tree, tokens []parser.Token) *EventReader {
+	return &EventReader{
+		tree:    tree,
+		tokens:  tokens,
+		nsStack: []nsFrame{{bindings: map[string]string{}}},
+	}
+}
+
+// Next returns the next structural event, or io.EOF once the token stream is
+// exhausted. It returns ErrMismatchedEndTag or ErrUnclosedElement (wrapped
+// with the offending position) if the document isn't well-formed.
+func (r *EventReader) Next() (Event, error) {
+	if len(r.pending) > 0 {
+		ev := r.pending[0]
+		r.pending = r.pending[1:]
+		return ev, nil
+	}
+
+	for r.pos < len(r.tokens) {
+		tok := r.tokens[r.pos]
+
+		if r.inDoctype {
+			// Text between a DOCTYPE's tokens is skipped along with them.
+			r.skipDoctypeToken(tok)
+			continue
+		}
+
+		if tok.StartPos > r.consumedPos {
+			return r.emitCharData(tok.StartPos), nil
+		}
+
+		if tok.Role == languages.XmlTokenRoleDoctype {
+			r.skipDoctypeToken(tok)
+			continue
+		}
+
+		switch tok.Role {
+		case parser.TokenRoleComment:
+			return r.emitDelimited(tok, EventComment, "<!--", "-->"), nil
+		case languages.XmlTokenRoleCData:
+			return r.emitDelimited(tok, EventCData, "<![CDATA[", "]]>"), nil
+		case languages.XmlTokenRolePrologue:
+			return r.emitPrologueOrPI(tok), nil
+		case languages.XmlTokenRoleTag:
+			return r.readTag()
+		default:
+			// Entity references and other standalone tokens in normal text
+			// don't start a structural event of their own; their source text
+			// (e.g. "&amp;") is still char data, so emit it as a CharData
+			// event of its own rather than skipping over it.
+			ev := r.emitCharData(tok.EndPos)
+			r.pos++
+			return ev, nil
+		}
+	}
+
+	if r.consumedPos < r.tree.NumChars() {
+		return r.emitCharData(r.tree.NumChars()), nil
+	}
+
+	if len(r.elems) > 0 {
+		top := r.elems[len(r.elems)-1]
+		return Event{}, fmt.Errorf("%w: %q at position %d", ErrUnclosedElement, top.rawName, top.pos)
+	}
+
+	return Event{}, io.EOF
+}
+
+func (r *EventReader) skipDoctypeToken(tok parser.Token) {
+	if tok.Role == languages.XmlTokenRoleDoctype && r.tokenText(tok) == ">" {
+		r.inDoctype = false
+	} else if tok.Role == languages.XmlTokenRoleDoctype {
+		r.inDoctype = true
+	}
+	r.pos++
+	r.consumedPos = tok.EndPos
+}
+
+func (r *EventReader) emitCharData(upTo uint64) Event {
+	ev := Event{Type: EventCharData, Pos: r.consumedPos, Data: r.textRange(r.consumedPos, upTo)}
+	r.consumedPos = upTo
+	return ev
+}
+
+func (r *EventReader) emitDelimited(tok parser.Token, evType EventType, prefix, suffix string) Event {
+	r.pos++
+	r.consumedPos = tok.EndPos
+	return Event{Type: evType, Pos: tok.StartPos, Data: trimDelims(r.tokenText(tok), prefix, suffix)}
+}
+
+func (r *EventReader) emitPrologueOrPI(tok parser.Token) Event {
+	r.pos++
+	r.consumedPos = tok.EndPos
+	evType := EventProcessingInstruction
+	if !r.sawPrologue {
+		evType = EventPrologue
+		r.sawPrologue = true
+	}
+	return Event{Type: evType, Pos: tok.StartPos, Data: trimDelims(r.tokenText(tok), "<?", "?>")}
+}
+
+// readTag consumes one full start or end tag (the tag-open token, any
+// attribute key/value tokens, and the tag-close token) and returns the
+// resulting StartElement or EndElement event. A self-closing start tag
+// queues its EndElement as a pending event.
+func (r *EventReader) readTag() (Event, error) {
+	openTok := r.tokens[r.pos]
+	openPos := openTok.StartPos
+	openText := r.tokenText(openTok)
+	isEndTag := strings.HasPrefix(openText, "</")
+	rawName := strings.TrimPrefix(strings.TrimPrefix(openText, "</"), "<")
+	r.pos++
+	r.consumedPos = openTok.EndPos
+
+	var rawAttrs []rawAttr
+	var pendingKey string
+
+	for r.pos < len(r.tokens) {
+		tok := r.tokens[r.pos]
+		switch tok.Role {
+		case languages.XmlTokenRoleAttrKey:
+			pendingKey = strings.TrimSuffix(r.tokenText(tok), "=")
+			r.pos++
+			r.consumedPos = tok.EndPos
+		case parser.TokenRoleString:
+			rawAttrs = append(rawAttrs, rawAttr{key: pendingKey, val: r.readAttrValue()})
+			pendingKey = ""
+		case languages.XmlTokenRoleTag:
+			closeText := r.tokenText(tok)
+			selfClosing := closeText == "/>"
+			r.pos++
+			r.consumedPos = tok.EndPos
+
+			if isEndTag {
+				return r.closeElement(rawName, openPos)
+			}
+
+			frame := r.pushNamespaceFrame(rawAttrs)
+			name := resolveElementName(rawName, frame)
+			attrs := resolveAttrs(rawAttrs, frame)
+
+			if selfClosing {
+				r.nsStack = r.nsStack[:len(r.nsStack)-1]
+				r.pending = append(r.pending, Event{Type: EventEndElement, Pos: openPos, Name: name})
+			} else {
+				r.elems = append(r.elems, openElement{rawName: rawName, name: name, frame: frame, pos: openPos})
+			}
+
+			return Event{Type: EventStartElement, Pos: openPos, Name: name, Attrs: attrs}, nil
+		default:
+			r.pos++
+			r.consumedPos = tok.EndPos
+		}
+	}
+
+	return Event{}, fmt.Errorf("xml: unterminated tag %q", rawName)
+}
+
+func (r *EventReader) closeElement(rawName string, pos uint64) (Event, error) {
+	if len(r.elems) == 0 {
+		return Event{}, fmt.Errorf("%w: %q at position %d has no opener", ErrMismatchedEndTag, rawName, pos)
+	}
+
+	top := r.elems[len(r.elems)-1]
+	if top.rawName != rawName {
+		return Event{}, fmt.Errorf("%w: %q at position %d does not match opener %q", ErrMismatchedEndTag, rawName, pos, top.rawName)
+	}
+
+	r.elems = r.elems[:len(r.elems)-1]
+	r.nsStack = r.nsStack[:len(r.nsStack)-1]
+	return Event{Type: EventEndElement, Pos: pos, Name: top.name}, nil
+}
+
+// pushNamespaceFrame builds the namespace frame in scope for a newly opened
+// element: a copy of the parent frame's bindings overridden by any xmlns /
+// xmlns:prefix attributes on this element, and pushes it onto the stack.
+func (r *EventReader) pushNamespaceFrame(rawAttrs []rawAttr) nsFrame {
+	parent := r.nsStack[len(r.nsStack)-1]
+	bindings := make(map[string]string, len(parent.bindings))
+	for k, v := range parent.bindings {
+		bindings[k] = v
+	}
+
+	for _, a := range rawAttrs {
+		switch {
+		case a.key == "xmlns":
+			bindings[""] = a.val
+		case strings.HasPrefix(a.key, "xmlns:"):
+			bindings[strings.TrimPrefix(a.key, "xmlns:")] = a.val
+		}
+	}
+
+	frame := nsFrame{bindings: bindings}
+	r.nsStack = append(r.nsStack, frame)
+	return frame
+}
+
+func resolveElementName(rawName string, frame nsFrame) Name {
+	prefix, local := splitPrefix(rawName)
+	return resolveName(prefix, local, frame, true)
+}
+
+func resolveAttrs(rawAttrs []rawAttr, frame nsFrame) []Attr {
+	attrs := make([]Attr, 0, len(rawAttrs))
+	for _, a := range rawAttrs {
+		if a.key == "xmlns" || strings.HasPrefix(a.key, "xmlns:") {
+			continue
+		}
+		prefix, local := splitPrefix(a.key)
+		attrs = append(attrs, Attr{Name: resolveName(prefix, local, frame, false), Value: a.val})
+	}
+	return attrs
+}
+
+// resolveName resolves a (prefix, local) pair to a namespace URI. Unlike
+// elements, unprefixed attributes never inherit the default namespace.
+func resolveName(prefix, local string, frame nsFrame, isElement bool) Name {
+	switch prefix {
+	case "xml":
+		return Name{Local: local, URI: xmlNamespaceURI}
+	case "xmlns":
+		return Name{Local: local, URI: xmlnsNamespaceURI}
+	case "":
+		if isElement {
+			if uri, ok := frame.resolve(""); ok {
+				return Name{Local: local, URI: uri}
+			}
+		}
+		return Name{Local: local}
+	default:
+		if uri, ok := frame.resolve(prefix); ok {
+			return Name{Local: local, URI: uri}
+		}
+		return Name{Local: local}
+	}
+}
+
+func splitPrefix(rawName string) (prefix, local string) {
+	if i := strings.IndexByte(rawName, ':'); i >= 0 {
+		return rawName[:i], rawName[i+1:]
+	}
+	return "", rawName
+}
+
+func trimDelims(s, prefix, suffix string) string {
+	s = strings.TrimPrefix(s, prefix)
+	s = strings.TrimSuffix(s, suffix)
+	return s
+}
+
+// readAttrValue consumes a full quoted attribute value starting at its
+// open-quote token (the current r.pos): the open quote, any text and
+// entity-reference tokens making up the body, and the matching close quote.
+// Tokenization splits the body into several tokens (so entity references get
+// their own TokenRole), so the body can't be read as a single token the way
+// the open/close quotes can; this stitches them back into one value.
+func (r *EventReader) readAttrValue() string {
+	openTok := r.tokens[r.pos]
+	quote := r.tokenText(openTok)
+	r.pos++
+	r.consumedPos = openTok.EndPos
+
+	var sb strings.Builder
+	for r.pos < len(r.tokens) {
+		tok := r.tokens[r.pos]
+		if tok.Role == parser.TokenRoleString && r.tokenText(tok) == quote {
+			r.pos++
+			r.consumedPos = tok.EndPos
+			break
+		}
+		sb.WriteString(r.tokenText(tok))
+		r.pos++
+		r.consumedPos = tok.EndPos
+	}
+	return sb.String()
+}
+
+func (r *EventReader) textRange(start, end uint64) string {
+	var sb strings.Builder
+	runeIter := text.NewCloneableForwardRuneIter(r.tree.ReaderAtPosition(start, text.ReadDirectionForward))
+	for i := start; i < end; i++ {
+		ru, err := runeIter.NextRune()
+		if err != nil {
+			break
+		}
+		sb.WriteRune(ru)
+	}
+	return sb.String()
+}
+
+func (r *EventReader) tokenText(tok parser.Token) string {
+	return r.textRange(tok.StartPos, tok.EndPos)
+}
@@ -0,0 +1,102 @@
+package xml
+
+import (
+	"io"
+	"testing"
+
+	"github.com/aretext/aretext/syntax/languages"
+	"github.com/aretext/aretext/syntax/parser"
+	"github.com/aretext/aretext/text"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadTagAttributeValue covers a self-closing tag with a single quoted
+// attribute value. XmlParseFunc tokenizes a quoted value as separate
+// open-quote, body, and close-quote tokens (all role TokenRoleString) rather
+// than one token for the whole value, so readTag must stitch them back
+// together into a single rawAttr instead of producing one rawAttr per token.
+func TestReadTagAttributeValue(t *testing.T) {
+	const src = `<a title="x"/>`
+	tree, err := text.NewTreeFromString(src)
+	assert.NoError(t, err)
+
+	tokens := []parser.Token{
+		{StartPos: 0, EndPos: 2, Role: languages.XmlTokenRoleTag},     // "<a"
+		{StartPos: 3, EndPos: 9, Role: languages.XmlTokenRoleAttrKey}, // "title="
+		{StartPos: 9, EndPos: 10, Role: parser.TokenRoleString},       // `"`
+		{StartPos: 10, EndPos: 11, Role: parser.TokenRoleString},      // "x"
+		{StartPos: 11, EndPos: 12, Role: parser.TokenRoleString},      // `"`
+		{StartPos: 12, EndPos: 14, Role: languages.XmlTokenRoleTag},   // "/>"
+	}
+
+	r := NewEventReader(tree, tokens)
+
+	start, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, EventStartElement, start.Type)
+	assert.Equal(t, []Attr{{Name: Name{Local: "title"}, Value: "x"}}, start.Attrs)
+
+	end, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, EventEndElement, end.Type)
+}
+
+// TestNextEmitsEntityReferenceAsCharData covers an entity reference
+// surrounded by plain text ("cats &amp; dogs"): Next must not silently skip
+// the entity token, since its source text is still part of the element's
+// character data.
+func TestNextEmitsEntityReferenceAsCharData(t *testing.T) {
+	const src = `cats &amp; dogs`
+	tree, err := text.NewTreeFromString(src)
+	assert.NoError(t, err)
+
+	tokens := []parser.Token{
+		{StartPos: 5, EndPos: 10, Role: languages.XmlTokenRoleEntityRef}, // "&amp;"
+	}
+
+	r := NewEventReader(tree, tokens)
+
+	var data string
+	for {
+		ev, err := r.Next()
+		if err != nil {
+			break
+		}
+		assert.Equal(t, EventCharData, ev.Type)
+		data += ev.Data
+	}
+
+	assert.Equal(t, src, data)
+}
+
+// TestNextSkipsDoctypeDeclaration covers a <!DOCTYPE ...> declaration with a
+// bracketed internal subset: every token tagged XmlTokenRoleDoctype (and, per
+// EventReader.inDoctype, everything between the opening token and the final
+// "]>") must be skipped rather than surfaced as char data or a tag event, and
+// the root element following it must still be read correctly.
+func TestNextSkipsDoctypeDeclaration(t *testing.T) {
+	const src = `<!DOCTYPE a [<!ENTITY b "c">]><a/>`
+	tree, err := text.NewTreeFromString(src)
+	assert.NoError(t, err)
+
+	tokens := []parser.Token{
+		{StartPos: 0, EndPos: 29, Role: languages.XmlTokenRoleDoctype},  // "<!DOCTYPE a [<!ENTITY b \"c\">]"
+		{StartPos: 29, EndPos: 30, Role: languages.XmlTokenRoleDoctype}, // ">"
+		{StartPos: 30, EndPos: 32, Role: languages.XmlTokenRoleTag},     // "<a"
+		{StartPos: 32, EndPos: 34, Role: languages.XmlTokenRoleTag},     // "/>"
+	}
+
+	r := NewEventReader(tree, tokens)
+
+	start, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, EventStartElement, start.Type)
+	assert.Equal(t, "a", start.Name.Local)
+
+	end, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, EventEndElement, end.Type)
+
+	_, err = r.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
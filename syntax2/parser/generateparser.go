@@ -0,0 +1,316 @@
+package parser
+
+import "errors"
+
+// GrammarSymbol is a node in a PEG-style grammar expression: a terminal (matched
+// against a token's TokenRole), a reference to another rule, or a composition of
+// other symbols (sequence, ordered choice, repetition, or optional).
+type GrammarSymbol interface {
+	isGrammarSymbol()
+}
+
+// TerminalSymbol matches a single token with the given role.
+type TerminalSymbol struct {
+	Role TokenRole
+}
+
+// RuleRefSymbol matches by recursively applying another named rule.
+type RuleRefSymbol struct {
+	RuleName string
+}
+
+// SequenceSymbol matches each of its symbols in order.
+type SequenceSymbol struct {
+	Symbols []GrammarSymbol
+}
+
+// ChoiceSymbol tries each alternative in order, committing to the first that matches
+// (ordered/PEG choice, not ambiguous LL alternation).
+type ChoiceSymbol struct {
+	Alternatives []GrammarSymbol
+}
+
+// RepeatedSymbol matches its child symbol zero or more times.
+type RepeatedSymbol struct {
+	Symbol GrammarSymbol
+}
+
+// OptionalSymbol matches its child symbol zero or one times.
+type OptionalSymbol struct {
+	Symbol GrammarSymbol
+}
+
+func (TerminalSymbol) isGrammarSymbol()  {}
+func (RuleRefSymbol) isGrammarSymbol()   {}
+func (SequenceSymbol) isGrammarSymbol()  {}
+func (ChoiceSymbol) isGrammarSymbol()    {}
+func (RepeatedSymbol) isGrammarSymbol()  {}
+func (OptionalSymbol) isGrammarSymbol()  {}
+
+// GrammarRule associates a name with the symbol it expands to.
+// Other rules refer to it by name through a RuleRefSymbol, so rules may reference each
+// other (including recursively); GenerateParser checks that every referenced name is
+// defined, and the parser looks the rule up by name while parsing.
+type GrammarRule struct {
+	Name string
+	Body GrammarSymbol
+}
+
+// Grammar is the input to GenerateParser: a start rule plus the full set of rules it
+// (transitively) references, and a set of anchor token roles used to resynchronize
+// after a parse error.
+type Grammar struct {
+	StartRule    string
+	Rules        []GrammarRule
+	AnchorTokens []TokenRole
+}
+
+// ErrUnknownRule is returned when a grammar references a rule name with no definition.
+var ErrUnknownRule = errors.New("grammar references an undefined rule")
+
+// Parser is a generated, deterministic PEG parser over a TokenTree.
+// It builds a SyntaxTree whose leaves reference the tokens already stored in the
+// tree it was given, rather than copying token data.
+type Parser struct {
+	grammar  Grammar
+	rulesMap map[string]GrammarSymbol
+}
+
+// GenerateParser validates a grammar and returns a Parser that can run it.
+// Every RuleRefSymbol transitively reachable from StartRule must resolve to a
+// defined rule, or GenerateParser returns ErrUnknownRule.
+func GenerateParser(grammar Grammar) (*Parser, error) {
+	rulesMap := make(map[string]GrammarSymbol, len(grammar.Rules))
+	for _, r := range grammar.Rules {
+		rulesMap[r.Name] = r.Body
+	}
+
+	if _, ok := rulesMap[grammar.StartRule]; !ok {
+		return nil, ErrUnknownRule
+	}
+
+	for _, r := range grammar.Rules {
+		if err := validateRuleRefs(r.Body, rulesMap); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Parser{grammar: grammar, rulesMap: rulesMap}, nil
+}
+
+func validateRuleRefs(sym GrammarSymbol, rulesMap map[string]GrammarSymbol) error {
+	switch s := sym.(type) {
+	case RuleRefSymbol:
+		if _, ok := rulesMap[s.RuleName]; !ok {
+			return ErrUnknownRule
+		}
+	case SequenceSymbol:
+		for _, child := range s.Symbols {
+			if err := validateRuleRefs(child, rulesMap); err != nil {
+				return err
+			}
+		}
+	case ChoiceSymbol:
+		for _, child := range s.Alternatives {
+			if err := validateRuleRefs(child, rulesMap); err != nil {
+				return err
+			}
+		}
+	case RepeatedSymbol:
+		return validateRuleRefs(s.Symbol, rulesMap)
+	case OptionalSymbol:
+		return validateRuleRefs(s.Symbol, rulesMap)
+	}
+	return nil
+}
+
+// ParseError describes a failure to match the grammar at a particular token position.
+type ParseError struct {
+	Pos uint64
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return e.Msg
+}
+
+// Parse runs the parser over every token in tree starting at the tree's first token,
+// returning a SyntaxTree rooted at StartRule. On a mismatch, it records a ParseError
+// and resynchronizes by skipping tokens until it finds one of the grammar's anchor
+// tokens, then resumes parsing from the start rule so a single syntax error doesn't
+// prevent the rest of the document from getting a (partial) tree.
+func (p *Parser) Parse(tree *TokenTree) (*SyntaxTree, []error) {
+	tokens := tree.IterFromPosition(0).Collect()
+	state := &parseState{tokens: tokens, parser: p}
+
+	var roots []*SyntaxTree
+	for state.pos < len(state.tokens) {
+		startPos := state.pos
+		node, ok := state.matchRule(p.grammar.StartRule)
+		if ok {
+			if node != nil {
+				roots = append(roots, node)
+			}
+			if state.pos == startPos {
+				// A start rule that legitimately matches zero tokens would
+				// otherwise spin here forever; skip one token to force
+				// progress. Unlike a failed match, this records no
+				// ParseError and does not resynchronize at an anchor.
+				state.pos++
+			}
+			continue
+		}
+
+		errPos := state.tokens[state.pos].StartPos
+		state.errs = append(state.errs, &ParseError{Pos: errPos, Msg: "failed to match " + p.grammar.StartRule})
+		state.resyncAtAnchor()
+	}
+
+	if len(roots) == 0 {
+		return nil, state.errs
+	}
+	if len(roots) == 1 {
+		return roots[0], state.errs
+	}
+	return NewSyntaxTree(p.grammar.StartRule, roots), state.errs
+}
+
+// parseState tracks the parser's position in the token stream during a single Parse call.
+type parseState struct {
+	tokens []Token
+	pos    int
+	parser *Parser
+	errs   []error
+}
+
+func (s *parseState) resyncAtAnchor() {
+	// Always advance at least one token so a zero-width anchor set can't loop forever.
+	s.pos++
+	for s.pos < len(s.tokens) {
+		if isAnchorToken(s.tokens[s.pos].TokenRole, s.parser.grammar.AnchorTokens) {
+			return
+		}
+		s.pos++
+	}
+}
+
+func isAnchorToken(role TokenRole, anchors []TokenRole) bool {
+	for _, a := range anchors {
+		if a == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *parseState) matchRule(ruleName string) (*SyntaxTree, bool) {
+	sym, ok := s.parser.rulesMap[ruleName]
+	if !ok {
+		return nil, false
+	}
+
+	startPos := s.pos
+	node, ok := s.matchSymbol(sym)
+	if !ok {
+		s.pos = startPos
+		return nil, false
+	}
+
+	if node == nil {
+		// The rule's body matched but produced no node (e.g. an Optional or
+		// Repeated symbol that matched zero times): that's a successful
+		// empty match, not a failure, so a RuleRefSymbol wrapping this rule
+		// can still succeed with no children.
+		return nil, true
+	}
+
+	if node.Rule == "" && !node.IsLeaf() {
+		node.Rule = ruleName
+	}
+
+	return node, true
+}
+
+func (s *parseState) matchSymbol(sym GrammarSymbol) (*SyntaxTree, bool) {
+	switch sym := sym.(type) {
+	case TerminalSymbol:
+		if s.pos >= len(s.tokens) || s.tokens[s.pos].TokenRole != sym.Role {
+			return nil, false
+		}
+		tok := s.tokens[s.pos]
+		s.pos++
+		return NewLeafSyntaxTree(tok), true
+
+	case RuleRefSymbol:
+		return s.matchRule(sym.RuleName)
+
+	case SequenceSymbol:
+		startPos := s.pos
+		var children []*SyntaxTree
+		for _, child := range sym.Symbols {
+			node, ok := s.matchSymbol(child)
+			if !ok {
+				s.pos = startPos
+				return nil, false
+			}
+			if node != nil {
+				children = append(children, node)
+			}
+		}
+		if len(children) == 0 {
+			// Every element matched (possibly empty, e.g. all Optional/Repeated),
+			// so the sequence as a whole matches empty rather than failing.
+			return nil, true
+		}
+		return NewSyntaxTree("", children), true
+
+	case ChoiceSymbol:
+		for _, alt := range sym.Alternatives {
+			startPos := s.pos
+			node, ok := s.matchSymbol(alt)
+			if ok {
+				return node, true
+			}
+			s.pos = startPos
+		}
+		return nil, false
+
+	case RepeatedSymbol:
+		var children []*SyntaxTree
+		for {
+			startPos := s.pos
+			node, ok := s.matchSymbol(sym.Symbol)
+			if !ok {
+				s.pos = startPos
+				break
+			}
+			if node != nil {
+				children = append(children, node)
+			}
+			if s.pos == startPos {
+				// The child matched without consuming any tokens (e.g. a
+				// RuleRefSymbol to a rule that is itself Optional/Repeated).
+				// Treat that as the final iteration instead of looping forever.
+				break
+			}
+		}
+		if len(children) == 0 {
+			// Zero repetitions is a successful match (this is "*", not "+"),
+			// matching OptionalSymbol's (nil, true) convention for empty matches.
+			return nil, true
+		}
+		return NewSyntaxTree("", children), true
+
+	case OptionalSymbol:
+		startPos := s.pos
+		node, ok := s.matchSymbol(sym.Symbol)
+		if !ok {
+			s.pos = startPos
+			return nil, true
+		}
+		return node, true
+
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	roleA TokenRole = 1
+	roleB TokenRole = 2
+)
+
+func tokenTreeOf(tokens ...Token) *TokenTree {
+	var tree *TokenTree
+	for _, tok := range tokens {
+		tree = tree.Insert(tok)
+	}
+	return tree
+}
+
+// TestZeroWidthRepeatedAndOptionalInSequence covers a sequence whose elements
+// are Optional/Repeated before a final terminal: "B? B* A" against input that
+// has no B token, only the trailing A that the sequence must still reach.
+func TestZeroWidthRepeatedAndOptionalInSequence(t *testing.T) {
+	grammar := Grammar{
+		StartRule: "Start",
+		Rules: []GrammarRule{
+			{
+				Name: "Start",
+				Body: SequenceSymbol{Symbols: []GrammarSymbol{
+					OptionalSymbol{Symbol: TerminalSymbol{Role: roleB}},
+					RepeatedSymbol{Symbol: TerminalSymbol{Role: roleB}},
+					TerminalSymbol{Role: roleA},
+				}},
+			},
+		},
+	}
+
+	p, err := GenerateParser(grammar)
+	assert.NoError(t, err)
+
+	tree := tokenTreeOf(Token{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA})
+
+	syntaxTree, errs := p.Parse(tree)
+	assert.Empty(t, errs)
+	if assert.NotNil(t, syntaxTree) {
+		assert.Equal(t, []Token{{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA}}, syntaxTree.Leaves())
+	}
+}
+
+// TestRepeatedSymbolMatchesZeroTimes covers "B*" nested inside a larger
+// sequence when there are zero B tokens to consume.
+func TestRepeatedSymbolMatchesZeroTimes(t *testing.T) {
+	grammar := Grammar{
+		StartRule: "Start",
+		Rules: []GrammarRule{
+			{
+				Name: "Start",
+				Body: SequenceSymbol{Symbols: []GrammarSymbol{
+					TerminalSymbol{Role: roleA},
+					RepeatedSymbol{Symbol: TerminalSymbol{Role: roleB}},
+					TerminalSymbol{Role: roleA},
+				}},
+			},
+		},
+	}
+
+	p, err := GenerateParser(grammar)
+	assert.NoError(t, err)
+
+	tree := tokenTreeOf(
+		Token{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA},
+		Token{StartPos: 1, EndPos: 2, LookaheadPos: 2, TokenRole: roleA},
+	)
+
+	syntaxTree, errs := p.Parse(tree)
+	assert.Empty(t, errs)
+	if assert.NotNil(t, syntaxTree) {
+		assert.Len(t, syntaxTree.Leaves(), 2)
+	}
+}
+
+// TestRuleRefMatchingZeroTokensSucceeds covers a named rule, referenced via
+// RuleRefSymbol, whose body legitimately matches zero tokens (an Optional
+// factored out into its own rule, as a grammar's "Attributes" or "Params"
+// rule would be). matchRule must not treat that rule's (nil, true) empty
+// match as a failure.
+func TestRuleRefMatchingZeroTokensSucceeds(t *testing.T) {
+	grammar := Grammar{
+		StartRule: "Start",
+		Rules: []GrammarRule{
+			{
+				Name: "Start",
+				Body: SequenceSymbol{Symbols: []GrammarSymbol{
+					RuleRefSymbol{RuleName: "MaybeB"},
+					TerminalSymbol{Role: roleA},
+				}},
+			},
+			{
+				Name: "MaybeB",
+				Body: OptionalSymbol{Symbol: TerminalSymbol{Role: roleB}},
+			},
+		},
+	}
+
+	p, err := GenerateParser(grammar)
+	assert.NoError(t, err)
+
+	tree := tokenTreeOf(Token{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA})
+
+	syntaxTree, errs := p.Parse(tree)
+	assert.Empty(t, errs)
+	if assert.NotNil(t, syntaxTree) {
+		assert.Equal(t, []Token{{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA}}, syntaxTree.Leaves())
+	}
+}
+
+// TestRepeatedSymbolWrappingNullableRuleRefTerminates covers "Attrs*" where
+// Attrs is itself a rule that can match zero tokens (e.g. Optional). Each
+// iteration of the RepeatedSymbol loop must detect that the child consumed no
+// tokens and stop, rather than matching the same zero-width rule forever.
+func TestRepeatedSymbolWrappingNullableRuleRefTerminates(t *testing.T) {
+	grammar := Grammar{
+		StartRule: "Start",
+		Rules: []GrammarRule{
+			{
+				Name: "Start",
+				Body: SequenceSymbol{Symbols: []GrammarSymbol{
+					RepeatedSymbol{Symbol: RuleRefSymbol{RuleName: "Attrs"}},
+					TerminalSymbol{Role: roleA},
+				}},
+			},
+			{
+				Name: "Attrs",
+				Body: OptionalSymbol{Symbol: TerminalSymbol{Role: roleB}},
+			},
+		},
+	}
+
+	p, err := GenerateParser(grammar)
+	assert.NoError(t, err)
+
+	tree := tokenTreeOf(Token{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA})
+
+	syntaxTree, errs := p.Parse(tree)
+	assert.Empty(t, errs)
+	if assert.NotNil(t, syntaxTree) {
+		assert.Equal(t, []Token{{StartPos: 0, EndPos: 1, LookaheadPos: 1, TokenRole: roleA}}, syntaxTree.Leaves())
+	}
+}
@@ -0,0 +1,64 @@
+package parser
+
+// ReTokenizeFunc resumes tokenization at startPos using resumeState as the DFA's
+// starting state, producing tokens until it either runs out of input or recognizes,
+// at or after minEnd, a token that re-converges with a token already present in
+// suffix (same StartPos and TokenRole). minEnd is the end of the widened span that
+// InvalidateRange determined must be re-tokenized; converging any earlier would
+// reuse a surviving token the edit may have actually invalidated. suffix holds the
+// tree's surviving tokens starting at or after startPos, which is the only way the
+// implementation can actually tell when it has reconverged rather than merely
+// producing a token that happens to look similar. convergedAt is only meaningful
+// when converged is true.
+type ReTokenizeFunc func(resumeState State, startPos, minEnd uint64, suffix *TokenTree) (tokens []Token, convergedAt uint64, converged bool)
+
+// ReTokenizeFrom incrementally updates tree to reflect an edit within [editStart, editEnd)
+// that changed the document's length by delta (newLength - oldLength; zero for a
+// same-length replacement, positive for an insertion, negative for a deletion), without
+// re-tokenizing the whole document. It invalidates every token touched by the edit,
+// resumes the tokenizer at the earliest invalidated boundary, and stops as soon as the
+// newly produced tokens re-converge, at or after the widened end of the invalidated span,
+// with the surviving suffix of the tree, Join-ing the recomputed tokens onto the surviving
+// prefix and suffix subtrees.
+//
+// tree's positions are all in pre-edit coordinates, but retokenize scans the post-edit
+// text, so the surviving suffix is shifted by delta via ShiftAfter before retokenize ever
+// sees it: that keeps every position retokenize compares (minEnd, convergedAt, and the
+// tokens inside suffix) in the same post-edit coordinate space.
+func ReTokenizeFrom(tree *TokenTree, resumeState State, editStart, editEnd uint64, delta int64, retokenize ReTokenizeFunc) *TokenTree {
+	invalidated, widenedStart, widenedEnd := tree.InvalidateRange(editStart, editEnd)
+
+	prefix, oldSuffix := splitAtPosition(invalidated, widenedStart)
+	suffix := oldSuffix.ShiftAfter(widenedStart, delta)
+	minEnd := uint64(int64(widenedEnd) + delta)
+
+	tokens, convergedAt, converged := retokenize(resumeState, widenedStart, minEnd, suffix)
+	for _, tok := range tokens {
+		prefix = prefix.Insert(tok)
+	}
+
+	if !converged {
+		// The tokenizer ran to the end of the document, so there's no surviving
+		// suffix left to Join back onto the recomputed prefix.
+		return prefix
+	}
+
+	_, survivingSuffix := splitAtPosition(suffix, convergedAt)
+	return prefix.Join(survivingSuffix)
+}
+
+// splitAtPosition partitions a tree's tokens into those starting before pos and
+// those starting at or after pos.
+func splitAtPosition(tree *TokenTree, pos uint64) (before, atOrAfter *TokenTree) {
+	iter := tree.IterFromPosition(0)
+	var tok Token
+	for iter.Get(&tok) {
+		if tok.StartPos < pos {
+			before = before.Insert(tok)
+		} else {
+			atOrAfter = atOrAfter.Insert(tok)
+		}
+		iter.Advance()
+	}
+	return before, atOrAfter
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReTokenizeFromPassesSurvivingSuffix(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 5, LookaheadPos: 5, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 5, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(2)})
+	tree = tree.Insert(Token{StartPos: 10, EndPos: 15, LookaheadPos: 15, TokenRole: TokenRole(1)})
+
+	var observedSuffix *TokenTree
+	var observedMinEnd uint64
+	var zeroState State
+	retokenize := func(resumeState State, startPos, minEnd uint64, suffix *TokenTree) ([]Token, uint64, bool) {
+		observedSuffix = suffix
+		observedMinEnd = minEnd
+		// Produce a single replacement token that reconverges with the token
+		// the real implementation would find at the start of suffix.
+		tok := Token{StartPos: startPos, EndPos: startPos + 5, LookaheadPos: startPos + 5, TokenRole: TokenRole(1)}
+		return []Token{tok}, startPos + 5, true
+	}
+
+	result := ReTokenizeFrom(tree, zeroState, 2, 3, 0, retokenize)
+
+	// The retokenizer must actually be given the surviving suffix so it can
+	// tell when it has reconverged, rather than being left to guess.
+	if assert.NotNil(t, observedSuffix) {
+		suffixTokens := observedSuffix.IterFromPosition(0).Collect()
+		assert.NotEmpty(t, suffixTokens)
+		for _, tok := range suffixTokens {
+			assert.GreaterOrEqual(t, tok.StartPos, uint64(5))
+		}
+	}
+
+	resultTokens := result.IterFromPosition(0).Collect()
+	assert.Len(t, resultTokens, 3)
+
+	// The edit at [2, 3) only fully invalidates the [0, 5) token, so the
+	// widened span the retokenizer must cover before converging extends to 5,
+	// not just to the edit's own end (3).
+	assert.Equal(t, uint64(5), observedMinEnd)
+}
+
+// TestReTokenizeFromShiftsSurvivingSuffixByDelta covers an edit that changes
+// the document's length (replacing 1 character with 3, delta=+2). The
+// surviving suffix's tokens are still in pre-edit coordinates, so
+// ReTokenizeFrom must shift them by delta before handing them to retokenize
+// and before Join-ing them back onto the recomputed prefix; otherwise every
+// token after the edit would end up pointing at the wrong text.
+func TestReTokenizeFromShiftsSurvivingSuffixByDelta(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 5, LookaheadPos: 5, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 5, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(2)})
+	tree = tree.Insert(Token{StartPos: 10, EndPos: 15, LookaheadPos: 15, TokenRole: TokenRole(1)})
+
+	var observedSuffixStart uint64
+	var observedMinEnd uint64
+	var zeroState State
+	retokenize := func(resumeState State, startPos, minEnd uint64, suffix *TokenTree) ([]Token, uint64, bool) {
+		observedMinEnd = minEnd
+		var tok Token
+		suffix.IterFromPosition(0).Get(&tok)
+		observedSuffixStart = tok.StartPos
+
+		produced := Token{StartPos: startPos, EndPos: minEnd, LookaheadPos: minEnd, TokenRole: TokenRole(1)}
+		return []Token{produced}, minEnd, true
+	}
+
+	result := ReTokenizeFrom(tree, zeroState, 2, 3, 2, retokenize)
+
+	// The [5, 10) token's pre-edit start (5) must already be shifted to 7 by
+	// the time retokenize sees it, so it can compare directly against the
+	// positions it produces while scanning the post-edit text.
+	assert.Equal(t, uint64(7), observedSuffixStart)
+	assert.Equal(t, uint64(7), observedMinEnd)
+
+	resultTokens := result.IterFromPosition(0).Collect()
+	assert.Equal(t, []Token{
+		{StartPos: 0, EndPos: 7, LookaheadPos: 7, TokenRole: TokenRole(1)},
+		{StartPos: 7, EndPos: 12, LookaheadPos: 12, TokenRole: TokenRole(2)},
+		{StartPos: 12, EndPos: 17, LookaheadPos: 17, TokenRole: TokenRole(1)},
+	}, resultTokens)
+}
@@ -0,0 +1,78 @@
+package parser
+
+// SyntaxTree is an immutable parse tree produced by a generated parser.
+// Each node carries both its token span (StartPos/EndPos) and its lookahead span
+// (LookaheadPos), mirroring Token's fields so the same invalidation machinery used
+// for TokenTree (ShiftAfter/InvalidateRange) can be reused for subtrees once a
+// syntax-aware incremental reparser is built on top of this package.
+type SyntaxTree struct {
+	// Rule is the name of the grammar rule that produced this node.
+	// It is empty for leaf nodes, which wrap a single token from the TokenTree.
+	Rule string
+
+	// Token is the leaf token this node wraps. It is only meaningful when Children is empty.
+	Token Token
+
+	// Children are this node's child nodes in left-to-right order.
+	Children []*SyntaxTree
+
+	StartPos     uint64
+	EndPos       uint64
+	LookaheadPos uint64
+}
+
+// NewLeafSyntaxTree wraps a single token as a leaf node.
+func NewLeafSyntaxTree(token Token) *SyntaxTree {
+	return &SyntaxTree{
+		Token:        token,
+		StartPos:     token.StartPos,
+		EndPos:       token.EndPos,
+		LookaheadPos: token.LookaheadPos,
+	}
+}
+
+// NewSyntaxTree combines child nodes under a named grammar rule.
+// children must be non-empty; its span is the union of its children's spans.
+func NewSyntaxTree(rule string, children []*SyntaxTree) *SyntaxTree {
+	if len(children) == 0 {
+		panic("SyntaxTree node must have at least one child")
+	}
+
+	startPos := children[0].StartPos
+	endPos := children[0].EndPos
+	lookaheadPos := children[0].LookaheadPos
+	for _, c := range children[1:] {
+		if c.EndPos > endPos {
+			endPos = c.EndPos
+		}
+		if c.LookaheadPos > lookaheadPos {
+			lookaheadPos = c.LookaheadPos
+		}
+	}
+
+	return &SyntaxTree{
+		Rule:         rule,
+		Children:     children,
+		StartPos:     startPos,
+		EndPos:       endPos,
+		LookaheadPos: lookaheadPos,
+	}
+}
+
+// IsLeaf reports whether this node wraps a single token rather than child nodes.
+func (t *SyntaxTree) IsLeaf() bool {
+	return len(t.Children) == 0
+}
+
+// Leaves returns every leaf token under this node, in order.
+func (t *SyntaxTree) Leaves() []Token {
+	if t.IsLeaf() {
+		return []Token{t.Token}
+	}
+
+	var result []Token
+	for _, c := range t.Children {
+		result = append(result, c.Leaves()...)
+	}
+	return result
+}
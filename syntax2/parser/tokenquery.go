@@ -0,0 +1,105 @@
+package parser
+
+// Find returns an iterator over every token for which pred returns true,
+// in order. This lets higher-level features (e.g. highlight all identifiers
+// under the cursor) query the tree once instead of linearly scanning Collect().
+func (t *TokenTree) Find(pred func(Token) bool) *TokenIter {
+	iter := t.IterFromPosition(0)
+	iter.pred = pred
+	return iter
+}
+
+// EnclosingToken returns the token with the given role that contains pos, if any.
+// Tokens never overlap, so at most one token can contain pos.
+func (t *TokenTree) EnclosingToken(pos uint64, role TokenRole) (Token, bool) {
+	var tok Token
+	if !t.IterFromPosition(pos).Get(&tok) {
+		return Token{}, false
+	}
+
+	if tok.StartPos <= pos && pos < tok.EndPos && tok.TokenRole == role {
+		return tok, true
+	}
+
+	return Token{}, false
+}
+
+// NextMatching returns the first token starting at or after pos for which
+// pred returns true, if any.
+func (t *TokenTree) NextMatching(pos uint64, pred func(Token) bool) (Token, bool) {
+	iter := t.IterFromPosition(pos)
+	// IterFromPosition(pos) starts from the token whose EndPos > pos, which can
+	// be a token that started before pos (one straddling pos); filter those out
+	// so a match always starts at or after pos, per this method's own contract.
+	iter.pred = func(tok Token) bool {
+		return tok.StartPos >= pos && pred(tok)
+	}
+
+	var tok Token
+	if !iter.Get(&tok) {
+		return Token{}, false
+	}
+	return tok, true
+}
+
+// RangeIter returns an iterator over every token whose span intersects
+// [start, end), pruning subtrees outside that range using the minStartPos/
+// maxEndPos metadata maintained by Insert.
+func (t *TokenTree) RangeIter(start, end uint64) *TokenIter {
+	var stack []*TokenTree
+	for t != nil {
+		if t.maxEndPos <= start || t.minStartPos >= end {
+			// The entire subtree falls outside the range.
+			break
+		}
+
+		if start < t.token.StartPos {
+			stack = append(stack, t)
+			t = t.leftChild
+		} else if start >= t.token.EndPos {
+			t = t.rightChild
+		} else {
+			stack = append(stack, t)
+			break
+		}
+	}
+
+	return &TokenIter{
+		stack: stack,
+		pred:  func(tok Token) bool { return tok.StartPos < end },
+	}
+}
+
+// Depth returns the length of the longest path from the root to a leaf.
+// An empty tree has depth zero.
+func (t *TokenTree) Depth() int {
+	if t == nil {
+		return 0
+	}
+
+	leftDepth := t.leftChild.Depth()
+	rightDepth := t.rightChild.Depth()
+	if leftDepth > rightDepth {
+		return leftDepth + 1
+	}
+	return rightDepth + 1
+}
+
+// Rebalance returns a tree containing the same tokens, restructured into a
+// balanced binary tree. Insert makes no balancing guarantee, so a pathological
+// insertion order (e.g. tokenizing a file strictly left-to-right) can otherwise
+// degrade the tree to a list and defeat the pruning that RangeIter relies on.
+func (t *TokenTree) Rebalance() *TokenTree {
+	return buildBalancedTokenTree(t.IterFromPosition(0).Collect())
+}
+
+func buildBalancedTokenTree(tokens []Token) *TokenTree {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	mid := len(tokens) / 2
+	left := buildBalancedTokenTree(tokens[:mid])
+	right := buildBalancedTokenTree(tokens[mid+1:])
+	return newTokenTree(tokens[mid], left, right)
+}
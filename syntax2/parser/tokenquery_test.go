@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextMatchingSkipsTokenStraddlingPos(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 10, EndPos: 20, LookaheadPos: 20, TokenRole: TokenRole(1)})
+
+	// pos=5 falls inside the first token ([0, 10)), which starts before pos.
+	// NextMatching promises the first token starting "at or after pos", so it
+	// must skip this one and return the second token instead.
+	tok, ok := tree.NextMatching(5, func(Token) bool { return true })
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(10), tok.StartPos)
+	}
+}
+
+func TestNextMatchingReturnsTokenStartingExactlyAtPos(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(1)})
+
+	tok, ok := tree.NextMatching(0, func(Token) bool { return true })
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(0), tok.StartPos)
+	}
+}
+
+func TestFindReturnsOnlyMatchingTokensInOrder(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 10, EndPos: 20, LookaheadPos: 20, TokenRole: TokenRole(2)})
+	tree = tree.Insert(Token{StartPos: 20, EndPos: 30, LookaheadPos: 30, TokenRole: TokenRole(1)})
+
+	toks := tree.Find(func(tok Token) bool { return tok.TokenRole == TokenRole(1) }).Collect()
+	assert.Len(t, toks, 2)
+	assert.Equal(t, uint64(0), toks[0].StartPos)
+	assert.Equal(t, uint64(20), toks[1].StartPos)
+}
+
+func TestEnclosingTokenMatchesPositionAndRole(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(1)})
+
+	tok, ok := tree.EnclosingToken(5, TokenRole(1))
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(0), tok.StartPos)
+	}
+
+	// Same position, wrong role: no match.
+	_, ok = tree.EnclosingToken(5, TokenRole(2))
+	assert.False(t, ok)
+
+	// Position outside any token: no match.
+	_, ok = tree.EnclosingToken(15, TokenRole(1))
+	assert.False(t, ok)
+}
+
+func TestRangeIterPrunesTokensOutsideRange(t *testing.T) {
+	var tree *TokenTree
+	tree = tree.Insert(Token{StartPos: 0, EndPos: 10, LookaheadPos: 10, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 10, EndPos: 20, LookaheadPos: 20, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 20, EndPos: 30, LookaheadPos: 30, TokenRole: TokenRole(1)})
+	tree = tree.Insert(Token{StartPos: 30, EndPos: 40, LookaheadPos: 40, TokenRole: TokenRole(1)})
+
+	toks := tree.RangeIter(15, 25).Collect()
+	assert.Len(t, toks, 2)
+	assert.Equal(t, uint64(10), toks[0].StartPos)
+	assert.Equal(t, uint64(20), toks[1].StartPos)
+}
+
+func TestDepthOfEmptyTreeIsZero(t *testing.T) {
+	var tree *TokenTree
+	assert.Equal(t, 0, tree.Depth())
+}
+
+func TestRebalanceReducesDepthOfSkewedTree(t *testing.T) {
+	var tree *TokenTree
+	for i := uint64(0); i < 8; i++ {
+		tree = tree.Insert(Token{StartPos: i * 10, EndPos: i*10 + 10, LookaheadPos: i*10 + 10, TokenRole: TokenRole(1)})
+	}
+
+	unbalancedDepth := tree.Depth()
+	balanced := tree.Rebalance()
+
+	assert.Less(t, balanced.Depth(), unbalancedDepth)
+	assert.Equal(t, tree.IterFromPosition(0).Collect(), balanced.IterFromPosition(0).Collect())
+}
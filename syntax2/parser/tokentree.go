@@ -69,6 +69,90 @@ func (t *TokenTree) withRightChild(child *TokenTree) *TokenTree {
 	}
 }
 
+// ShiftAfter returns a tree with the start, end, and lookahead positions of every
+// token whose StartPos >= pos adjusted by delta.
+// This is used to keep a tree's positions consistent after an edit inserts or
+// removes characters at pos without forcing the caller to re-tokenize unaffected tokens.
+func (t *TokenTree) ShiftAfter(pos uint64, delta int64) *TokenTree {
+	if t == nil {
+		return nil
+	} else if t.maxEndPos <= pos {
+		// Every token in this subtree ends before pos, so none of them qualify for a shift.
+		return t
+	}
+
+	token := t.token
+	if token.StartPos >= pos {
+		token = shiftToken(token, delta)
+	}
+
+	return newTokenTree(
+		token,
+		t.leftChild.ShiftAfter(pos, delta),
+		t.rightChild.ShiftAfter(pos, delta),
+	)
+}
+
+func shiftToken(token Token, delta int64) Token {
+	token.StartPos = shiftPos(token.StartPos, delta)
+	token.EndPos = shiftPos(token.EndPos, delta)
+	token.LookaheadPos = shiftPos(token.LookaheadPos, delta)
+	return token
+}
+
+func shiftPos(pos uint64, delta int64) uint64 {
+	return uint64(int64(pos) + delta)
+}
+
+// newTokenTree constructs a node from a token and its (possibly modified) children,
+// recalculating the minStartPos/maxEndPos metadata from scratch.
+func newTokenTree(token Token, leftChild, rightChild *TokenTree) *TokenTree {
+	minStartPos := token.StartPos
+	if leftChild != nil && leftChild.minStartPos < minStartPos {
+		minStartPos = leftChild.minStartPos
+	}
+
+	maxEndPos := token.EndPos
+	if rightChild != nil && rightChild.maxEndPos > maxEndPos {
+		maxEndPos = rightChild.maxEndPos
+	}
+
+	return &TokenTree{
+		token:       token,
+		minStartPos: minStartPos,
+		maxEndPos:   maxEndPos,
+		leftChild:   leftChild,
+		rightChild:  rightChild,
+	}
+}
+
+// InvalidateRange removes every token whose [StartPos, LookaheadPos) span intersects
+// [start, end), returning the resulting tree along with a widened [start, end) span
+// that accounts for the lookahead of each removed token. Callers should re-tokenize
+// the widened span and Join the result back onto the returned tree.
+func (t *TokenTree) InvalidateRange(start, end uint64) (*TokenTree, uint64, uint64) {
+	var result *TokenTree
+	widenedStart, widenedEnd := start, end
+
+	iter := t.IterFromPosition(0)
+	var tok Token
+	for iter.Get(&tok) {
+		if tok.StartPos < end && tok.LookaheadPos > start {
+			if tok.StartPos < widenedStart {
+				widenedStart = tok.StartPos
+			}
+			if tok.LookaheadPos > widenedEnd {
+				widenedEnd = tok.LookaheadPos
+			}
+		} else {
+			result = result.Insert(tok)
+		}
+		iter.Advance()
+	}
+
+	return result, widenedStart, widenedEnd
+}
+
 // Join combines two trees into a single tree.
 // The spans (start of first token to end of last token) of the two trees must not overlap.
 func (t *TokenTree) Join(other *TokenTree) *TokenTree {
@@ -122,7 +206,7 @@ func (t *TokenTree) IterFromPosition(pos uint64) *TokenIter {
 			break
 		}
 	}
-	return &TokenIter{stack}
+	return &TokenIter{stack: stack}
 }
 
 // TokenIter iterates over tokens.
@@ -130,10 +214,15 @@ type TokenIter struct {
 	// Stack of nodes to visit next.
 	// The last element (top of the stack) is the current node.
 	stack []*TokenTree
+
+	// pred, if set, restricts iteration to tokens for which it returns true.
+	// Tokens that fail pred are skipped transparently by Get/Advance.
+	pred func(Token) bool
 }
 
 // Get retrieves the current token, if it exists.
 func (iter *TokenIter) Get(tok *Token) bool {
+	iter.skipToMatch()
 	if len(iter.stack) == 0 {
 		return false
 	}
@@ -150,6 +239,13 @@ func (iter *TokenIter) Advance() {
 		return
 	}
 
+	iter.advanceRaw()
+	iter.skipToMatch()
+}
+
+// advanceRaw moves to the next node in the tree's in-order traversal,
+// ignoring pred.
+func (iter *TokenIter) advanceRaw() {
 	// Pop the current node from the stack,
 	// and push all the left children of the current node's right subtree.
 	t := iter.stack[len(iter.stack)-1].rightChild
@@ -160,6 +256,22 @@ func (iter *TokenIter) Advance() {
 	}
 }
 
+// skipToMatch advances past any tokens that fail pred, leaving the stack
+// positioned at the next matching token (or empty if none remain).
+func (iter *TokenIter) skipToMatch() {
+	if iter.pred == nil {
+		return
+	}
+
+	for len(iter.stack) > 0 {
+		t := iter.stack[len(iter.stack)-1]
+		if iter.pred(t.token) {
+			return
+		}
+		iter.advanceRaw()
+	}
+}
+
 // Collect retrieves all tokens from the iterator and returns them as a slice.
 func (iter *TokenIter) Collect() []Token {
 	result := make([]Token, 0)